@@ -0,0 +1,327 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// rangeOperators are the comparisons that are combined within $elemMatch for an
+// array field so that each comparison applies to the same element
+var rangeOperators = map[string]bool{
+	"$gt":  true,
+	"$gte": true,
+	"$lt":  true,
+	"$lte": true,
+}
+
+// isArray determines if the field is defined as an array within the schema
+func (qb QueryBuilder) isArray(field string) bool {
+	return qb.arrayFields[field] || qb.fieldTypes[field] == "array"
+}
+
+// arrayOperatorFilter builds the clause for an array field using the all
+// ($all), size ($size) or exact (array equality) named operators... values are
+// parsed as the bsonType of the items in the array
+func (qb QueryBuilder) arrayOperatorFilter(field string, oper string, values []string, bsonType string) (bson.M, error) {
+	if !qb.isArray(field) {
+		return nil, &UnsupportedOperatorError{
+			Field:    field,
+			Value:    strings.Join(values, ","),
+			Operator: oper,
+			Reason:   "only array fields support the all, exact and size operators",
+		}
+	}
+
+	if oper == "$size" {
+		if len(values) > 1 {
+			return nil, invalidValue(field, strings.Join(values, ","), "", fmt.Errorf("expected a single number of items"))
+		}
+
+		n, err := strconv.ParseUint(values[0], 10, 31)
+		if err != nil {
+			return nil, invalidValue(field, values[0], "", err)
+		}
+
+		return bson.M{field: bson.D{bson.E{
+			Key:   oper,
+			Value: int32(n),
+		}}}, nil
+	}
+
+	a := bson.A{}
+	for _, v := range values {
+		tv, err := qb.itemValue(field, v, bsonType)
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, tv)
+	}
+
+	// the array must contain exactly the values in the order provided
+	if oper == "exact" {
+		return bson.M{field: a}, nil
+	}
+
+	return bson.M{field: bson.D{bson.E{
+		Key:   oper,
+		Value: a,
+	}}}, nil
+}
+
+// itemValue parses a single value for an item of an array field... dates and
+// timestamps are compared as an instant (the start of a day, month or year)
+func (qb QueryBuilder) itemValue(field string, value string, bsonType string) (any, error) {
+	var dv dateValue
+	var err error
+
+	switch bsonType {
+	case "date":
+		dv, err = qb.dateParser().parse(value)
+	case "timestamp":
+		dv, err = parseTimestamp(value, qb.dateParser())
+	default:
+		return qb.typedValue(field, value, bsonType)
+	}
+
+	if err != nil {
+		return nil, invalidValue(field, value, bsonType, err)
+	}
+
+	return dv.start, nil
+}
+
+// matchArrayElements wraps multiple range comparisons for an array field (i.e.
+// { scores: { $gte: 80, $lt: 90 } }) in $elemMatch so that the comparisons
+// apply to the same element of the array rather than to any of the elements
+func (qb QueryBuilder) matchArrayElements(filter bson.M) bson.M {
+	for field, v := range filter {
+		if !qb.isArray(field) {
+			continue
+		}
+
+		expr, ok := v.(bson.D)
+		if !ok || len(expr) < 2 {
+			continue
+		}
+
+		match := true
+		for _, e := range expr {
+			if !rangeOperators[e.Key] {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			filter[field] = bson.D{bson.E{Key: "$elemMatch", Value: expr}}
+		}
+	}
+
+	return filter
+}
+
+// mergeRangeClauses merges the range comparisons that are combined with $and
+// for multiple values of a field (i.e. filter[scores]=>=80,<90) into a single
+// operator expression for the field
+func mergeRangeClauses(field string, clause bson.M) bson.M {
+	a, ok := clause[And.String()].(bson.A)
+	if !ok || len(clause) != 1 {
+		return clause
+	}
+
+	expr := bson.D{}
+	for _, c := range a {
+		d, ok := c.(bson.D)
+		if !ok || len(d) != 1 || d[0].Key != field {
+			return clause
+		}
+
+		ce, ok := d[0].Value.(bson.D)
+		if !ok || len(ce) != 1 || !rangeOperators[ce[0].Key] || hasOperator(expr, ce) {
+			return clause
+		}
+
+		expr = append(expr, ce[0])
+	}
+
+	return bson.M{field: expr}
+}
+
+// matchSubDocuments groups the clauses for fields within the same array of
+// sub-documents (i.e. authors.name and authors.email) into $elemMatch so that
+// the clauses apply to the same element of the array rather than to any of the
+// elements... clauses are only grouped when combined with $and because the
+// grouping does not change the meaning of $or and changes the meaning of $nor
+func (qb QueryBuilder) matchSubDocuments(clauses []bson.M, lo LogicalOperator) []bson.M {
+	if lo != And || !qb.opts.subDocumentMatch() {
+		return clauses
+	}
+
+	return qb.elementClauses(clauses, "")
+}
+
+// elementClauses groups the clauses that share an array of sub-documents below
+// the parent path... arrays of sub-documents within each group are grouped in
+// turn so that nested arrays result in a nested $elemMatch
+func (qb QueryBuilder) elementClauses(clauses []bson.M, parent string) []bson.M {
+	grouped := []bson.M{}
+	groups := map[string][]bson.M{}
+	paths := []string{}
+
+	for _, c := range clauses {
+		p := qb.elementPath(c, parent)
+		if p == "" {
+			grouped = append(grouped, c)
+			continue
+		}
+
+		if _, ok := groups[p]; !ok {
+			paths = append(paths, p)
+		}
+
+		groups[p] = append(groups[p], c)
+	}
+
+	for _, p := range paths {
+		// a single clause for an array of sub-documents is not grouped
+		if len(groups[p]) < 2 {
+			grouped = append(grouped, groups[p]...)
+			continue
+		}
+
+		// fields within $elemMatch are relative to the element
+		members := []bson.M{}
+		for _, c := range qb.elementClauses(groups[p], p) {
+			members = append(members, relativeClause(c, fmt.Sprintf("%s.", p)).(bson.M))
+		}
+
+		grouped = append(grouped, bson.M{p: bson.D{bson.E{
+			Key:   "$elemMatch",
+			Value: combineClauses(members, And),
+		}}})
+	}
+
+	return grouped
+}
+
+// elementPath returns the outermost array of sub-documents below the parent
+// path that contains every field within the clause, or an empty string when
+// the fields do not share an array of sub-documents
+func (qb QueryBuilder) elementPath(clause bson.M, parent string) string {
+	fields := clauseFields(clause)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	path := ""
+	for i, f := range fields {
+		p := ""
+		segments := strings.Split(f, ".")
+
+		for j := range segments[:len(segments)-1] {
+			ap := strings.Join(segments[:j+1], ".")
+			if len(ap) <= len(parent) {
+				continue
+			}
+
+			if qb.arrayFields[ap] && qb.fieldTypes[ap] == "object" {
+				p = ap
+				break
+			}
+		}
+
+		if p == "" || (i > 0 && p != path) {
+			return ""
+		}
+
+		path = p
+	}
+
+	return path
+}
+
+// clauseFields returns each of the fields within a clause, including the fields
+// within any $and, $or and $nor... nil is returned when the clause contains any
+// other top level operator
+func clauseFields(clause any) []string {
+	var elems bson.D
+	switch c := clause.(type) {
+	case bson.M:
+		for k, v := range c {
+			elems = append(elems, bson.E{Key: k, Value: v})
+		}
+	case bson.D:
+		elems = c
+	default:
+		return nil
+	}
+
+	fields := []string{}
+	for _, e := range elems {
+		switch e.Key {
+		case And.String(), Or.String(), Nor.String():
+			a, ok := e.Value.(bson.A)
+			if !ok {
+				return nil
+			}
+
+			for _, sub := range a {
+				sf := clauseFields(sub)
+				if sf == nil {
+					return nil
+				}
+
+				fields = append(fields, sf...)
+			}
+		default:
+			if strings.HasPrefix(e.Key, "$") {
+				return nil
+			}
+
+			fields = append(fields, e.Key)
+		}
+	}
+
+	return fields
+}
+
+// relativeClause removes the prefix from each of the fields within a clause,
+// including the fields within any $and, $or and $nor
+func relativeClause(clause any, prefix string) any {
+	rel := func(k string, v any) any {
+		a, ok := v.(bson.A)
+		if !ok || !strings.HasPrefix(k, "$") {
+			return v
+		}
+
+		ra := bson.A{}
+		for _, sub := range a {
+			ra = append(ra, relativeClause(sub, prefix))
+		}
+
+		return ra
+	}
+
+	switch c := clause.(type) {
+	case bson.M:
+		rc := bson.M{}
+		for k, v := range c {
+			rc[strings.TrimPrefix(k, prefix)] = rel(k, v)
+		}
+
+		return rc
+	case bson.D:
+		rc := bson.D{}
+		for _, e := range c {
+			rc = append(rc, bson.E{Key: strings.TrimPrefix(e.Key, prefix), Value: rel(e.Key, e.Value)})
+		}
+
+		return rc
+	}
+
+	return clause
+}
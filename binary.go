@@ -0,0 +1,132 @@
+package querybuilder
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// base64Prefix identifies a base64 binData value (i.e. base64:aGVsbG8=)... base64
+// must be explicit because an even length hex string (i.e. abcd) is also valid
+// base64 and URL safe base64 may begin with - (which is treated as $ne)
+const base64Prefix = "base64:"
+
+var (
+	reHex  = regexp.MustCompile(`^(?:[0-9a-fA-F]{2})+$`)
+	reUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// parseBinary parses a binData value, which may be any of the following:
+// * a canonical UUID (i.e. 123e4567-e89b-12d3-a456-426614174000), which is
+// subtype 4 (UUID) unless another subtype is provided
+// * a hex string with an even number of characters (i.e. deadbeef)
+// * a standard or URL safe base64 string, with or without padding, following
+// the base64: prefix (i.e. base64:aGVsbG8=)
+//
+// Values other than a UUID are subtype 0 (generic) unless another subtype is
+// provided... the UUID subtypes (3 and 4) require 16 bytes.
+func parseBinary(value string, subtype *byte) (primitive.Binary, error) {
+	var data []byte
+	var err error
+	st := bson.TypeBinaryGeneric
+
+	switch {
+	case strings.HasPrefix(value, base64Prefix):
+		data, err = decodeBase64(strings.TrimPrefix(value, base64Prefix))
+	case reUUID.MatchString(value):
+		data, err = hex.DecodeString(strings.ReplaceAll(value, "-", ""))
+		st = bson.TypeBinaryUUID
+	case reHex.MatchString(value):
+		data, err = hex.DecodeString(value)
+	default:
+		err = fmt.Errorf("unknown encoding")
+	}
+
+	if err != nil {
+		return primitive.Binary{}, fmt.Errorf("expected a UUID, hex or base64: prefixed value")
+	}
+
+	if subtype != nil {
+		st = *subtype
+	}
+
+	if (st == bson.TypeBinaryUUIDOld || st == bson.TypeBinaryUUID) && len(data) != 16 {
+		return primitive.Binary{}, fmt.Errorf("expected 16 bytes for a UUID subtype, got %d", len(data))
+	}
+
+	return primitive.Binary{Subtype: st, Data: data}, nil
+}
+
+// decodeBase64 decodes a standard or URL safe base64 string with or without
+// padding
+func decodeBase64(value string) ([]byte, error) {
+	var err error
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		var data []byte
+		if data, err = enc.DecodeString(value); err == nil {
+			return data, nil
+		}
+	}
+
+	return nil, err
+}
+
+// detectBinaryComparisonOperator builds the clause for a binData field... binary
+// values can only be compared for equality, so the "!=" and "-" prefixes are
+// treated as $ne (and $nin for multiple values) and other operators are not
+// supported
+func detectBinaryComparisonOperator(field string, values []string, lo LogicalOperator, subtype *byte) (bson.M, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	ovs := []operatorValue{}
+	for _, v := range values {
+		value, oper := detectComparisonOperator(v, true)
+
+		if oper != "" && oper != "$ne" {
+			return nil, &UnsupportedOperatorError{
+				Field:    field,
+				Value:    v,
+				Operator: oper,
+				Reason:   "binData fields only support equality, $in and $nin comparisons",
+			}
+		}
+
+		// detect usage of keyword "null"
+		if value == "null" {
+			ovs = append(ovs, operatorValue{oper, nil})
+			continue
+		}
+
+		b, err := parseBinary(value, subtype)
+		if err != nil {
+			return nil, invalidValue(field, value, "binData", err)
+		}
+
+		ovs = append(ovs, operatorValue{oper, b})
+	}
+
+	if len(ovs) > 1 {
+		return multipleValuesFilter(field, ovs, lo), nil
+	}
+
+	if ovs[0].oper != "" {
+		return bson.M{field: bson.D{bson.E{
+			Key:   ovs[0].oper,
+			Value: ovs[0].value,
+		}}}, nil
+	}
+
+	return bson.M{field: ovs[0].value}, nil
+}
@@ -0,0 +1,88 @@
+package querybuilder
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_parseBinary(t *testing.T) {
+	uuid := []byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	legacy := bson.TypeBinaryUUIDOld
+
+	tests := []struct {
+		name    string
+		value   string
+		subtype *byte
+		want    primitive.Binary
+		wantErr bool
+	}{
+		{
+			name:  "should parse a UUID as subtype 4",
+			value: "123e4567-e89b-12d3-a456-426614174000",
+			want:  primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: uuid},
+		},
+		{
+			name:    "should parse a UUID with the provided subtype",
+			value:   "123E4567-E89B-12D3-A456-426614174000",
+			subtype: &legacy,
+			want:    primitive.Binary{Subtype: bson.TypeBinaryUUIDOld, Data: uuid},
+		},
+		{
+			name:  "should parse hex as subtype 0",
+			value: "deadbeef",
+			want:  primitive.Binary{Subtype: bson.TypeBinaryGeneric, Data: []byte{0xde, 0xad, 0xbe, 0xef}},
+		},
+		{
+			name:  "should parse hex that is also valid base64 as hex",
+			value: "abcd",
+			want:  primitive.Binary{Subtype: bson.TypeBinaryGeneric, Data: []byte{0xab, 0xcd}},
+		},
+		{
+			name:  "should parse prefixed base64 with and without padding",
+			value: "base64:aGVsbG8",
+			want:  primitive.Binary{Subtype: bson.TypeBinaryGeneric, Data: []byte("hello")},
+		},
+		{
+			name:  "should parse prefixed base64 that is also valid hex",
+			value: "base64:abcd",
+			want:  primitive.Binary{Subtype: bson.TypeBinaryGeneric, Data: []byte{0x69, 0xb7, 0x1d}},
+		},
+		{
+			name:  "should parse prefixed URL safe base64",
+			value: "base64:-_8=",
+			want:  primitive.Binary{Subtype: bson.TypeBinaryGeneric, Data: []byte{0xfb, 0xff}},
+		},
+		{
+			name:    "should error for base64 without the prefix",
+			value:   "aGVsbG8=",
+			wantErr: true,
+		},
+		{
+			name:    "should error for a UUID subtype that is not 16 bytes",
+			value:   "deadbeef",
+			subtype: &legacy,
+			wantErr: true,
+		},
+		{
+			name:    "should error for a value that is not UUID, hex or base64",
+			value:   "not binary!",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBinary(tt.value, tt.subtype)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseBinary() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBinary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
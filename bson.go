@@ -2,10 +2,9 @@ package querybuilder
 
 import (
 	"fmt"
-	"reflect"
 	"regexp"
 	"strconv"
-	"time"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,49 +15,123 @@ var (
 	reWord = regexp.MustCompile(`\w+`)
 )
 
-func iterateProperties(parentPrefix string, properties bson.M, ft *map[string]string) {
+// schemaFields is the model of the fields that are defined within a schema...
+// types contains the bsonType of each field (or the bsonType of the items for
+// an array field), unions contains every type allowed for a field that allows
+// more than one type (other than null), arrays contains each field that is an
+// array and geo contains each field that is a GeoJSON object (with type and
+// coordinates)
+type schemaFields struct {
+	types  map[string]string
+	unions map[string][]string
+	arrays map[string]bool
+	geo    map[string]bool
+	defs   schemaDefinitions
+}
+
+func newSchemaFields() schemaFields {
+	return schemaFields{
+		types:  map[string]string{},
+		unions: map[string][]string{},
+		arrays: map[string]bool{},
+		geo:    map[string]bool{},
+		defs:   schemaDefinitions{},
+	}
+}
+
+func iterateProperties(parentPrefix string, properties bson.M, sf *schemaFields, refs map[string]bool) {
 	// iterate each field within properties
 	for field, value := range properties {
 		switch value := value.(type) {
 		case bson.M:
-			// retrieve the type of the field
-			if bsonType, ok := value["bsonType"]; ok {
-				bsonType := bsonType.(string)
-				// capture type in the fieldTypes map
-				if bsonType != "" {
-					(*ft)[fmt.Sprintf("%s%s", parentPrefix, field)] = bsonType
+			path := fmt.Sprintf("%s%s", parentPrefix, field)
+
+			// resolve any $ref, anyOf, oneOf and allOf (the references resolved
+			// for this field apply to the sub-documents of the field as well)
+			fr := map[string]bool{}
+			for ref := range refs {
+				fr[ref] = true
+			}
+
+			value = sf.defs.flatten(value, fr)
+
+			// retrieve the types of the field
+			bsonTypes := schemaTypes(value["bsonType"])
+			if len(bsonTypes) == 0 {
+				// check for enum (without bsonType specified)
+				if _, ok := value["enum"]; ok {
+					sf.types[path] = "object"
 				}
 
-				if bsonType == "array" {
-					// look at "items" to get the bsonType
-					if items, ok := value["items"]; ok {
-						value = items.(bson.M)
-
-						// fix for issue where Array of type strings is not properly
-						// allowing filter with $in keyword
-						if bsonType, ok := value["bsonType"]; ok {
-							bsonType := bsonType.(string)
-							// capture type in the fieldTypes map
-							if bsonType != "" {
-								(*ft)[fmt.Sprintf("%s%s", parentPrefix, field)] = bsonType
-							}
-						}
-					}
+				continue
+			}
+
+			types := []string{}
+			subProperties := []bson.M{}
+			if p, ok := value["properties"].(bson.M); ok {
+				subProperties = append(subProperties, p)
+			}
+
+			for _, bsonType := range bsonTypes {
+				if bsonType != "array" {
+					types = appendType(types, bsonType)
+					continue
 				}
 
-				// handle any sub-document schema details
-				if subProperties, ok := value["properties"]; ok {
-					subProperties := subProperties.(bson.M)
-					iterateProperties(
-						fmt.Sprintf("%s%s.", parentPrefix, field), subProperties, ft)
+				// keep track of the field being an array
+				sf.arrays[path] = true
+
+				// look at "items" to get the bsonType (so that an array of strings
+				// is able to be filtered with $in)
+				items, ok := value["items"].(bson.M)
+				if !ok {
+					types = appendType(types, bsonType)
+					continue
 				}
 
-				continue
+				items = sf.defs.flatten(items, fr)
+				itemTypes := schemaTypes(items["bsonType"])
+				if len(itemTypes) == 0 {
+					itemTypes = []string{bsonType}
+				}
+
+				for _, it := range itemTypes {
+					types = appendType(types, it)
+				}
+
+				if p, ok := items["properties"].(bson.M); ok {
+					subProperties = append(subProperties, p)
+				}
+			}
+
+			// capture type in the fieldTypes map (the first type other than null)
+			// along with every type for a field that allows several types
+			allowed := []string{}
+			for _, t := range types {
+				if t != "null" {
+					allowed = append(allowed, t)
+				}
 			}
 
-			// check for enum (without bsonType specified)
-			if _, ok := value["enum"]; ok {
-				(*ft)[fmt.Sprintf("%s%s", parentPrefix, field)] = "object"
+			if len(allowed) == 0 {
+				allowed = types
+			}
+
+			sf.types[path] = allowed[0]
+			if len(allowed) > 1 {
+				sf.unions[path] = allowed
+			}
+
+			// handle any sub-document schema details
+			if len(subProperties) > 0 {
+				sp := mergeProperties(subProperties...)
+
+				// keep track of GeoJSON objects (i.e. { type: "Point", coordinates: [lng, lat] })
+				if isGeoJSON(sp) {
+					sf.geo[path] = true
+				}
+
+				iterateProperties(fmt.Sprintf("%s.", path), sp, sf, fr)
 			}
 		default:
 			// properties are not of type bson.M
@@ -67,102 +140,105 @@ func iterateProperties(parentPrefix string, properties bson.M, ft *map[string]st
 	}
 }
 
-func parseMapSchema(schema map[string]interface{}) map[string]string {
-	// convert a map to a bson.M
-	var conv func(map[string]any) bson.M
-	conv = func(m map[string]any) bson.M {
-		bm := bson.M{}
-		for k, v := range m {
-			if k == "bsonType" && reflect.TypeOf(v).String() == "[]string" {
-				bm[k] = v.([]string)[0]
-				continue
-			}
-
-			if sm, ok := v.(map[string]any); ok {
-				bm[k] = conv(sm)
-				continue
-			}
-
-			bm[k] = v
+// appendType appends the type when it is not already present
+func appendType(types []string, t string) []string {
+	for _, et := range types {
+		if et == t {
+			return types
 		}
-
-		return bm
 	}
 
-	return parseBSONSchema(conv(schema))
+	return append(types, t)
 }
 
-func parseBSONSchema(schema bson.M) map[string]string {
+func parseBSONSchema(schema bson.M) schemaFields {
 	// check to see if top level is $jsonSchema
-	if js, ok := schema["$jsonSchema"]; ok {
-		schema = js.(bson.M)
+	if js, ok := schema["$jsonSchema"].(bson.M); ok {
+		schema = js
 	}
 
 	// bsonType, required, properties at top level
 	// looking for properties field, specifically
-	flds := map[string]string{}
-	if properties, ok := schema["properties"]; ok {
-		properties := properties.(bson.M)
-		iterateProperties("", properties, &flds)
+	sf := newSchemaFields()
+	sf.defs = newSchemaDefinitions(schema)
+
+	root := sf.defs.flatten(schema, map[string]bool{})
+	if properties, ok := root["properties"].(bson.M); ok {
+		iterateProperties("", properties, &sf, map[string]bool{})
 	}
 
 	// return empty map
-	return flds
+	return sf
 }
 
-func parseJSONSchema(schema []byte) map[string]string {
-	// convert JSON to a map
+// decodeSchema converts a schema provided as a bson.M, map[string]any, []byte
+// (marshalled JSON) or string (serialized JSON) to a bson.M with any nested
+// documents and arrays converted to bson.M and bson.A... a nil schema is empty
+func decodeSchema(schema any) (bson.M, error) {
 	m := map[string]any{}
-	_ = bson.UnmarshalExtJSON(schema, false, &m)
-
-	return parseMapSchema(m)
-}
 
-func parseSchema(schema any) map[string]string {
-	// parse the schema
-	if schema != nil {
-		// look for a map[string]any as the schema
-		if s, ok := schema.(map[string]any); ok {
-			return parseMapSchema(s)
+	switch s := schema.(type) {
+	case nil:
+	case bson.M:
+		return normalizeSchema(s).(bson.M), nil
+	case map[string]any:
+		m = s
+	case []byte:
+		if err := bson.UnmarshalExtJSON(s, false, &m); err != nil {
+			return nil, err
 		}
-
-		// look for a bson.M as the schema
-		if s, ok := schema.(bson.M); ok {
-			return parseBSONSchema(s)
+	case string:
+		if err := bson.UnmarshalExtJSON([]byte(s), false, &m); err != nil {
+			return nil, err
 		}
+	default:
+		return nil, fmt.Errorf("expected a bson.M, map[string]any, []byte or string schema, got %T", schema)
+	}
 
-		// look for a []bit (marshalled JSON) as the schema
-		if s, ok := schema.([]byte); ok {
-			return parseJSONSchema(s)
-		}
+	return normalizeSchema(m).(bson.M), nil
+}
 
-		// look for a string (serialized JSON) as the schema
-		if s, ok := schema.(string); ok {
-			return parseStringSchema(s)
-		}
+// parseSchema returns the bsonType of each field defined within the schema
+func parseSchema(schema any) map[string]string {
+	return parseSchemaFields(schema).types
+}
+
+// parseSchemaFields parses the schema into the model of the fields... a schema
+// that can not be decoded has no fields (use validateSchema to report why)
+func parseSchemaFields(schema any) schemaFields {
+	m, err := decodeSchema(schema)
+	if err != nil {
+		return newSchemaFields()
 	}
 
-	return map[string]string{}
+	return parseBSONSchema(m)
 }
 
-func parseStringSchema(schema string) map[string]string {
-	// convert JSON string to a map
-	m := map[string]any{}
-	_ = bson.UnmarshalExtJSON([]byte(schema), false, &m)
+func parseNumericValue(value string, numericType string) (interface{}, error) {
+	switch numericType {
+	case "decimal":
+		// decimal values are parsed as Decimal128 to preserve precision
+		return primitive.ParseDecimal128(value)
+	case "double", "number":
+		// number is the alias for any numeric type and is parsed as a double
+		return strconv.ParseFloat(value, 64)
+	case "int":
+		v, err := strconv.ParseInt(value, 0, 32)
+		return int32(v), err
+	case "long":
+		return strconv.ParseInt(value, 0, 64)
+	}
 
-	return parseMapSchema(m)
+	return nil, fmt.Errorf("%s is not a numeric type", numericType)
 }
 
-func parseUTCDate(value string) time.Time {
-	dv, err := time.Parse(time.RFC3339, value)
-	if err != nil {
-		dv, err = time.Parse("2006-01-02", value)
-		if err != nil {
-			dv, _ = time.Parse("2006/01/02", value)
-		}
+func invalidValue(field string, value string, bsonType string, err error) error {
+	return &InvalidValueError{
+		Field:    field,
+		Value:    value,
+		BSONType: bsonType,
+		Reason:   err.Error(),
 	}
-
-	return dv.UTC()
 }
 
 func detectComparisonOperator(value string, isTime bool) (string, string) {
@@ -229,63 +305,127 @@ func detectComparisonOperator(value string, isTime bool) (string, string) {
 	return value, oper
 }
 
-func detectDateComparisonOperator(field string, values []string, lo LogicalOperator) bson.M {
+func detectBoolComparisonOperator(field string, values []string) (bson.M, error) {
 	if len(values) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// if values is greater than 0, use an $in clause
 	if len(values) > 1 {
-		a := bson.A{}
 		ina := bson.A{}
-		op := false
 
-		// add each string value to the bson.A
 		for _, v := range values {
-			v, oper := detectComparisonOperator(v, false)
-			dv := parseUTCDate(v)
-
-			// if there is an operator, structure the clause to include
-			// the operator
-			if oper != "" {
-				op = true
-				a = append(a, bson.D{bson.E{
-					Key: field,
-					Value: bson.D{bson.E{
-						Key:   oper,
-						Value: dv,
-					}}}})
-				continue
-			}
-
-			ina = append(ina, dv)
-		}
-
-		// determine type of query
-		if op {
-			// add any $in elements to the outer clause
-			if len(ina) > 0 {
-				a = append(a, bson.D{bson.E{
-					Key: field,
-					Value: bson.D{bson.E{
-						Key:   "$in",
-						Value: ina,
-					}},
-				}})
+			bv, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, invalidValue(field, v, "bool", err)
 			}
 
-			return bson.M{
-				lo.String(): a,
-			}
+			ina = append(ina, bv)
 		}
 
-		// return a filter with the array of values...
 		return bson.M{
 			field: bson.D{bson.E{
 				Key:   "$in",
 				Value: ina,
 			}},
+		}, nil
+	}
+
+	// check for an operator in the value (only $ne is meaningful for bool)
+	value, oper := detectComparisonOperator(values[0], true)
+	if oper != "" && oper != "$ne" {
+		return nil, &UnsupportedOperatorError{
+			Field:    field,
+			Value:    values[0],
+			Operator: oper,
+			Reason:   "bool fields only support equality and $ne comparisons",
+		}
+	}
+
+	// detect usage of keyword "null"
+	var bv interface{}
+	if !reNull.MatchString(value) {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, invalidValue(field, value, "bool", err)
+		}
+
+		bv = v
+	}
+
+	if oper != "" {
+		return bson.M{field: bson.D{bson.E{
+			Key:   oper,
+			Value: bv,
+		}}}, nil
+	}
+
+	return bson.M{field: bv}, nil
+}
+
+func detectDateComparisonOperator(field string, values []string, bsonType string, lo LogicalOperator, exclusive bool, parse func(string) (dateValue, error)) (bson.M, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	bound := func(v string, oper string) (bson.E, error) {
+		dv, err := parse(v)
+		if err != nil {
+			return bson.E{}, err
+		}
+
+		return dv.bound(oper), nil
+	}
+
+	// if values is greater than 0, use an $in clause
+	if len(values) > 1 {
+		ovs := []operatorValue{}
+
+		for _, v := range values {
+			// check for a range (i.e. 2024-01-01..2024-02-01)
+			expr, ok, err := rangeExpression(v, exclusive, bound)
+			if err != nil {
+				return nil, invalidValue(field, v, bsonType, err)
+			}
+
+			if ok {
+				ovs = append(ovs, operatorValue{rangeOperator, expr})
+				continue
+			}
+
+			v, oper := detectComparisonOperator(v, true)
+			dv, err := parse(v)
+			if err != nil {
+				return nil, invalidValue(field, v, bsonType, err)
+			}
+
+			// a whole day or partial date (i.e. 2024-05) is compared as a range
+			if dv.isRange() {
+				if oper == "" || oper == "$ne" {
+					ovs = append(ovs, operatorValue{oper, rangeValue(dv.expression(""))})
+					continue
+				}
+
+				ovs = append(ovs, operatorValue{rangeOperator, bson.D{dv.bound(oper)}})
+				continue
+			}
+
+			// the bound of an instant may differ from the start (i.e. the last
+			// increment of a timestamp second for $gt and $lte)
+			ovs = append(ovs, operatorValue{oper, dv.bound(oper).Value})
 		}
+
+		return multipleValuesFilter(field, ovs, lo), nil
+	}
+
+	// check for a range (i.e. 2024-01-01..2024-02-01)
+	expr, ok, err := rangeExpression(values[0], exclusive, bound)
+	if err != nil {
+		return nil, invalidValue(field, values[0], bsonType, err)
+	}
+
+	if ok {
+		return bson.M{field: expr}, nil
 	}
 
 	// check for an operator in the value
@@ -298,122 +438,106 @@ func detectDateComparisonOperator(field string, values []string, lo LogicalOpera
 			return bson.M{field: bson.D{bson.E{
 				Key:   oper,
 				Value: nil,
-			}}}
+			}}}, nil
 		}
 
 		// return the filter
-		return bson.M{field: nil}
+		return bson.M{field: nil}, nil
 	}
 
 	// parse the date value
-	dv := parseUTCDate(value)
+	dv, err := parse(value)
+	if err != nil {
+		return nil, invalidValue(field, value, bsonType, err)
+	}
+
+	// a whole day or partial date (i.e. 2024-05) is compared as a range
+	if dv.isRange() {
+		if oper == "" || oper == "$ne" {
+			return bson.M{field: dv.expression(oper)}, nil
+		}
+
+		return bson.M{field: bson.D{dv.bound(oper)}}, nil
+	}
 
 	// check if there is an lt, lte, gt or gte key
 	if oper != "" {
-		return bson.M{field: bson.D{bson.E{
-			Key:   oper,
-			Value: dv,
-		}}}
+		return bson.M{field: bson.D{dv.bound(oper)}}, nil
 	}
 
 	// return the filter
-	return bson.M{field: dv}
+	return bson.M{field: dv.start}, nil
+}
+
+// detectTimestampComparisonOperator builds the filter for a timestamp field...
+// values are parsed as a primitive.Timestamp and otherwise support the same
+// operators as dates (a T,I pair is split by the querystring parser, so a pair
+// in parentheses is joined back together first)
+func detectTimestampComparisonOperator(field string, values []string, lo LogicalOperator, exclusive bool, dp dateParser) (bson.M, error) {
+	return detectDateComparisonOperator(
+		field,
+		joinTimestampPairs(values),
+		"timestamp",
+		lo,
+		exclusive,
+		func(v string) (dateValue, error) {
+			return parseTimestamp(v, dp)
+		})
 }
 
-func detectNumericComparisonOperator(field string, values []string, numericType string, lo LogicalOperator) bson.M {
+func detectNumericComparisonOperator(field string, values []string, numericType string, lo LogicalOperator, exclusive bool) (bson.M, error) {
 	if len(values) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	var bitSize int
 	switch numericType {
-	case "decimal":
-		bitSize = 32
-	case "double":
-		bitSize = 64
-	case "int":
-		bitSize = 32
-	case "long":
-		bitSize = 64
+	case "decimal", "double", "int", "long", "number":
 	default:
-		return nil
+		return nil, nil
+	}
+
+	bound := func(v string, oper string) (bson.E, error) {
+		nv, err := parseNumericValue(v, numericType)
+		return bson.E{Key: oper, Value: nv}, err
 	}
 
 	// handle when values is an array
 	if len(values) > 1 {
-		a := bson.A{}
-		ina := bson.A{}
-		op := false
+		ovs := []operatorValue{}
 
 		for _, value := range values {
-			value, oper := detectComparisonOperator(value, false)
-
-			var pv interface{}
-			if numericType == "decimal" || numericType == "double" {
-				v, _ := strconv.ParseFloat(value, bitSize)
-				pv = v
-
-				// retype 32 bit
-				if bitSize == 32 {
-					pv = float32(v)
-				}
+			// check for a range (i.e. 10..20)
+			expr, ok, err := rangeExpression(value, exclusive, bound)
+			if err != nil {
+				return nil, invalidValue(field, value, numericType, err)
 			}
 
-			if pv == nil {
-				v, _ := strconv.ParseInt(value, 0, bitSize)
-				pv = v
-
-				// retype 32 bit
-				if bitSize == 32 {
-					pv = int32(v)
-				}
-			}
-
-			// if there is an operator, structure the clause to include
-			// the operator
-			if oper != "" {
-				op = true
-				a = append(a, bson.D{bson.E{
-					Key: field,
-					Value: bson.D{bson.E{
-						Key:   oper,
-						Value: pv,
-					}}}})
+			if ok {
+				ovs = append(ovs, operatorValue{rangeOperator, expr})
 				continue
 			}
 
-			// otherwise, just add the item to the list
-			// TODO: lots of testing required here...
-			// may need to add an operator when one isn't present
-			// because the mongo query may be incorrect otherwise
-			ina = append(ina, pv)
-		}
-
-		// determine type of query
-		if op {
-			// add any $in elements to the outer clause
-			if len(ina) > 0 {
-				a = append(a, bson.D{bson.E{
-					Key: field,
-					Value: bson.D{bson.E{
-						Key:   "$in",
-						Value: ina,
-					}},
-				}})
-			}
+			value, oper := detectComparisonOperator(value, false)
 
-			return bson.M{
-				lo.String(): a,
+			pv, err := parseNumericValue(value, numericType)
+			if err != nil {
+				return nil, invalidValue(field, value, numericType, err)
 			}
-		}
 
-		// return a filter with the array of values...
-		return bson.M{
-			field: bson.D{bson.E{
-				Key:   "$in",
-				Value: ina,
-			}},
+			ovs = append(ovs, operatorValue{oper, pv})
 		}
+
+		return multipleValuesFilter(field, ovs, lo), nil
+	}
+
+	// check for a range (i.e. 10..20)
+	expr, ok, err := rangeExpression(values[0], exclusive, bound)
+	if err != nil {
+		return nil, invalidValue(field, values[0], numericType, err)
+	}
+
+	if ok {
+		return bson.M{field: expr}, nil
 	}
 
 	// check for an operator in the value
@@ -434,32 +558,16 @@ func detectNumericComparisonOperator(field string, values []string, numericType
 			return bson.M{field: bson.D{bson.E{
 				Key:   oper,
 				Value: nil,
-			}}}
+			}}}, nil
 		}
 
-		return bson.M{field: nil}
+		return bson.M{field: nil}, nil
 	}
 
 	// parse the numeric value appropriately
-	var parsedValue interface{}
-	if numericType == "decimal" || numericType == "double" {
-		v, _ := strconv.ParseFloat(value, bitSize)
-		parsedValue = v
-
-		// retype 32 bit
-		if bitSize == 32 {
-			parsedValue = float32(v)
-		}
-	}
-
-	if parsedValue == nil {
-		v, _ := strconv.ParseInt(value, 0, bitSize)
-		parsedValue = v
-
-		// retype 32 bit
-		if bitSize == 32 {
-			parsedValue = int32(v)
-		}
+	parsedValue, err := parseNumericValue(value, numericType)
+	if err != nil {
+		return nil, invalidValue(field, value, numericType, err)
 	}
 
 	// check if there is an lt, lte, gt or gte key
@@ -468,16 +576,101 @@ func detectNumericComparisonOperator(field string, values []string, numericType
 		return bson.M{field: bson.D{bson.E{
 			Key:   oper,
 			Value: parsedValue,
-		}}}
+		}}}, nil
 	}
 
 	// no operator... just the value
-	return bson.M{field: parsedValue}
+	return bson.M{field: parsedValue}, nil
+}
+
+// rangeExpression builds the operator expression for a range literal (i.e.
+// 10..20, ..20 or 10..) using inclusive ($gte and $lte) or exclusive ($gt and
+// $lt) bounds... the bound function parses each side of the range for the
+// operator and false is returned when the value is not a range
+func rangeExpression(value string, exclusive bool, bound func(string, string) (bson.E, error)) (bson.D, bool, error) {
+	lower, upper, ok := strings.Cut(value, "..")
+	if !ok {
+		return nil, false, nil
+	}
+
+	if (lower == "" && upper == "") || strings.Contains(upper, "..") {
+		return nil, true, fmt.Errorf("expected a range with at least one bound (i.e. 10..20, ..20 or 10..)")
+	}
+
+	lop, uop := "$gte", "$lte"
+	if exclusive {
+		lop, uop = "$gt", "$lt"
+	}
+
+	expr := bson.D{}
+
+	if lower != "" {
+		lb, err := bound(lower, lop)
+		if err != nil {
+			return nil, true, err
+		}
+
+		expr = append(expr, lb)
+	}
+
+	if upper != "" {
+		ub, err := bound(upper, uop)
+		if err != nil {
+			return nil, true, err
+		}
+
+		expr = append(expr, ub)
+	}
+
+	return expr, true, nil
+}
+
+func detectObjectIDComparisonOperator(field string, values []string, lo LogicalOperator) (bson.M, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	// check for an operator in each value (objectId values are never negative,
+	// so the "-" prefix is safe to treat as $ne) and parse each hex string value
+	// into an ObjectID
+	ovs := []operatorValue{}
+	for _, v := range values {
+		value, oper := detectComparisonOperator(v, true)
+
+		// detect usage of keyword "null"
+		if value == "null" {
+			ovs = append(ovs, operatorValue{oper, nil})
+			continue
+		}
+
+		oid, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, invalidValue(field, value, "objectId", err)
+		}
+
+		ovs = append(ovs, operatorValue{oper, oid})
+	}
+
+	// if values is greater than 0, use an $in clause
+	if len(ovs) > 1 {
+		return multipleValuesFilter(field, ovs, lo), nil
+	}
+
+	// check if there is an lt, lte, gt, gte or ne key
+	if ovs[0].oper != "" {
+		return bson.M{field: bson.D{bson.E{
+			Key:   ovs[0].oper,
+			Value: ovs[0].value,
+		}}}, nil
+	}
+
+	// return the filter
+	return bson.M{field: ovs[0].value}, nil
 }
 
-func detectStringComparisonOperator(field string, values []string, bsonType string) bson.M {
+func detectStringComparisonOperator(field string, values []string, bsonType string, lo LogicalOperator, pb patternBuilder) (bson.M, error) {
 	if len(values) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// if bsonType is object, query should use an exists operator
@@ -507,36 +700,67 @@ func detectStringComparisonOperator(field string, values []string, bsonType stri
 			}}
 		}
 
-		return filter
+		return filter, nil
 	}
 
 	// if values is greater than 0, use an $in clause
 	if len(values) > 1 {
-		a := bson.A{}
+		// when type is an array, don't use $in operator
+		if bsonType == "array" {
+			a := bson.A{}
 
-		// add each string value to the bson.A
-		for _, v := range values {
-			a = append(a, v)
+			// add each string value to the bson.A
+			for _, v := range values {
+				a = append(a, v)
+			}
+
+			return bson.M{field: a}, nil
 		}
 
-		// when type is an array, don't use $in operator
-		if bsonType == "array" {
-			return bson.M{field: a}
+		ovs := []operatorValue{}
+
+		// check for a "-" or "!=" prefix on each value to detect negation
+		for _, v := range values {
+			switch {
+			case len(v) >= 3 && v[0:2] == "!=":
+				ovs = append(ovs, operatorValue{"$ne", v[2:]})
+			case len(v) >= 2 && v[0:1] == "-":
+				ovs = append(ovs, operatorValue{"$ne", v[1:]})
+			default:
+				// a raw regex may be included with the values for $in
+				rv, ok, err := pb.raw(v)
+				if err != nil {
+					return nil, invalidValue(field, v, bsonType, err)
+				}
+
+				if ok {
+					ovs = append(ovs, operatorValue{"", rv})
+					continue
+				}
+
+				ovs = append(ovs, operatorValue{"", v})
+			}
 		}
 
 		// create a filter with the array of values using an $in operator for strings...
-		return bson.M{field: bson.D{bson.E{
-			Key:   "$in",
-			Value: a,
-		}}}
+		return multipleValuesFilter(field, ovs, lo), nil
 	}
 
 	// single value
 	value := values[0]
 
+	// raw regex (when enabled)...
+	if rv, ok, err := pb.raw(value); ok {
+		if err != nil {
+			return nil, invalidValue(field, value, bsonType, err)
+		}
+
+		return bson.M{field: rv}, nil
+	}
+
 	// ensure we have a word/value to filter with
 	if !reWord.MatchString(value) {
-		return nil
+		return nil, nil
 	}
 
 	bw := false
@@ -588,10 +812,10 @@ func detectStringComparisonOperator(field string, values []string, bsonType stri
 			return bson.M{field: bson.D{bson.E{
 				Key:   "$ne",
 				Value: nil,
-			}}}
+			}}}, nil
 		}
 
-		return bson.M{field: nil}
+		return bson.M{field: nil}, nil
 	}
 
 	// not equal...
@@ -599,43 +823,326 @@ func detectStringComparisonOperator(field string, values []string, bsonType stri
 		return bson.M{field: bson.D{bson.E{
 			Key:   "$ne",
 			Value: value,
-		}}}
+		}}}, nil
 	}
 
 	// contains...
 	if c {
-		return bson.M{field: primitive.Regex{
-			Pattern: value,
-			Options: "i",
-		}}
+		return bson.M{field: pb.match(value, "contains")}, nil
 	}
 
 	// begins with...
 	if bw {
-		return bson.M{field: primitive.Regex{
-			Pattern: fmt.Sprintf("^%s", value),
-			Options: "i",
-		}}
+		return bson.M{field: pb.match(value, "startsWith")}, nil
 	}
 
 	// ends with...
 	if ew {
-		return bson.M{field: primitive.Regex{
-			Pattern: fmt.Sprintf("%s$", value),
-			Options: "i",
-		}}
+		return bson.M{field: pb.match(value, "endsWith")}, nil
 	}
 
-	// exact match...
+	// exact match (always case sensitive)...
 	if em {
 		return bson.M{field: primitive.Regex{
-			Pattern: fmt.Sprintf("^%s$", value),
+			Pattern: fmt.Sprintf("^%s$", regexp.QuoteMeta(value)),
 			Options: "",
-		}}
+		}}, nil
 	}
 
 	// the string value as is...
-	return bson.M{field: value}
+	return bson.M{field: value}, nil
+}
+
+// rangeOperator identifies an operatorValue whose value is the operator
+// expression for a range literal
+const rangeOperator = ".."
+
+// operatorValue is a parsed value along with any operator detected in the value
+type operatorValue struct {
+	oper  string
+	value any
+}
+
+// rangeValue is a value that matches a range rather than a single value (i.e. a
+// whole day or month) and is expressed as an operator expression with bounds
+type rangeValue bson.D
+
+// valueSet is the set of values (and ranges) that are compared for equality or
+// inequality with multiple values of a field
+type valueSet struct {
+	values bson.A
+	ranges []bson.D
+}
+
+func (vs *valueSet) add(v any) {
+	if rv, ok := v.(rangeValue); ok {
+		vs.ranges = append(vs.ranges, bson.D(rv))
+		return
+	}
+
+	vs.values = append(vs.values, v)
+}
+
+func (vs valueSet) empty() bool {
+	return len(vs.values) == 0 && len(vs.ranges) == 0
+}
+
+// clause builds the clause that matches any of the values in the set ($in) or,
+// when negated, none of the values in the set ($ne or $nin)... ranges can not
+// be used with $in, so each range is added to an $or (or $nor when negated)
+func (vs valueSet) clause(field string, negate bool) bson.D {
+	if len(vs.ranges) == 0 {
+		if !negate {
+			return bson.D{bson.E{Key: field, Value: bson.D{bson.E{Key: "$in", Value: vs.values}}}}
+		}
+
+		if len(vs.values) == 1 {
+			return bson.D{bson.E{Key: field, Value: bson.D{bson.E{Key: "$ne", Value: vs.values[0]}}}}
+		}
+
+		return bson.D{bson.E{Key: field, Value: bson.D{bson.E{Key: "$nin", Value: vs.values}}}}
+	}
+
+	a := bson.A{}
+	if len(vs.values) > 0 {
+		a = append(a, bson.D{bson.E{Key: field, Value: bson.D{bson.E{Key: "$in", Value: vs.values}}}})
+	}
+
+	for _, r := range vs.ranges {
+		a = append(a, bson.D{bson.E{Key: field, Value: r}})
+	}
+
+	// a single range is compared directly
+	if len(a) == 1 {
+		c := a[0].(bson.D)
+		if negate {
+			return bson.D{bson.E{Key: field, Value: negateExpression(c[0].Value)}}
+		}
+
+		return c
+	}
+
+	if negate {
+		return bson.D{bson.E{Key: Nor.String(), Value: a}}
+	}
+
+	return bson.D{bson.E{Key: Or.String(), Value: a}}
+}
+
+// multipleValuesFilter builds the filter for multiple values of a single field.
+// Values without an operator are compared using $in and every negated value is
+// collapsed into a single $nin (or $ne for a single negated value). When any
+// other operator is present, or when positive and negated values are mixed, each
+// clause is combined using the provided logical operator:
+//
+//	// ?filter[status]=-archived,-deleted
+//	{ "status": { "$nin": [ "archived", "deleted" ] } }
+//
+//	// ?filter[status]=open,-archived
+//	{ "$and": [ { "status": { "$ne": "archived" } }, { "status": { "$in": [ "open" ] } } ] }
+func multipleValuesFilter(field string, ovs []operatorValue, lo LogicalOperator) bson.M {
+	a := bson.A{}
+	in := valueSet{}
+	nin := valueSet{}
+	ni := -1
+
+	for _, ov := range ovs {
+		switch ov.oper {
+		case "":
+			in.add(ov.value)
+		case "$ne":
+			// reserve the position of the first negated value for the clause
+			if ni < 0 {
+				ni = len(a)
+				a = append(a, nil)
+			}
+
+			nin.add(ov.value)
+		case rangeOperator:
+			a = append(a, bson.D{bson.E{
+				Key:   field,
+				Value: ov.value,
+			}})
+		default:
+			a = append(a, bson.D{bson.E{
+				Key: field,
+				Value: bson.D{bson.E{
+					Key:   ov.oper,
+					Value: ov.value,
+				}}}})
+		}
+	}
+
+	// without any operators, the values are compared with $in
+	if len(a) == 0 {
+		return clauseFilter(in.clause(field, false))
+	}
+
+	// collapse the negated values into a single clause
+	if ni >= 0 {
+		ne := nin.clause(field, true)
+
+		// only negated values
+		if len(a) == 1 && in.empty() {
+			return clauseFilter(ne)
+		}
+
+		a[ni] = ne
+	}
+
+	// add any $in elements to the outer clause
+	if !in.empty() {
+		a = append(a, in.clause(field, false))
+	}
+
+	// an $or is nested within $and so that it is not merged with the $or of
+	// another field (merging the $and or $nor of two fields is equivalent)
+	if lo == Or {
+		return bson.M{And.String(): bson.A{bson.M{lo.String(): a}}}
+	}
+
+	return bson.M{lo.String(): a}
+}
+
+// clauseFilter converts a single clause to a filter... a logical operator is
+// nested within $and so that it is not merged with the clauses of other fields
+func clauseFilter(c bson.D) bson.M {
+	if c[0].Key == Or.String() || c[0].Key == Nor.String() {
+		return bson.M{And.String(): bson.A{c}}
+	}
+
+	return bson.M{c[0].Key: c[0].Value}
+}
+
+// negateClause negates the operator expression for each field in the clause
+// (i.e. $in becomes $nin, equality becomes $ne and any other operator
+// expression is wrapped in $not). Any logical operator in the clause (i.e. a
+// $nor created for multiple values) is wrapped in a $nor.
+func negateClause(clause bson.M) bson.M {
+	negated := bson.M{}
+
+	for k, v := range clause {
+		// $not is only valid as a field operator, so logical operators are
+		// negated with $nor... a $nor is already negated
+		if len(k) > 0 && k[0:1] == "$" {
+			if k == Nor.String() {
+				negated = combine(negated, bson.M{k: v})
+				continue
+			}
+
+			negated = combine(negated, bson.M{Nor.String(): bson.A{bson.M{k: v}}})
+			continue
+		}
+
+		negated[k] = negateExpression(v)
+	}
+
+	return negated
+}
+
+func negateExpression(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return bson.D{bson.E{Key: "$ne", Value: nil}}
+	case primitive.Regex:
+		return bson.D{bson.E{Key: "$not", Value: v}}
+	case bson.D:
+		// an operator expression with a single operator may have an inverse
+		if len(v) == 1 {
+			switch v[0].Key {
+			case "$in":
+				return bson.D{bson.E{Key: "$nin", Value: v[0].Value}}
+			case "$nin":
+				return bson.D{bson.E{Key: "$in", Value: v[0].Value}}
+			case "$eq":
+				return bson.D{bson.E{Key: "$ne", Value: v[0].Value}}
+			case "$ne":
+				return bson.D{bson.E{Key: "$eq", Value: v[0].Value}}
+			case "$exists":
+				if b, ok := v[0].Value.(bool); ok {
+					return bson.D{bson.E{Key: "$exists", Value: !b}}
+				}
+			}
+		}
+
+		// any other operator expression (i.e. $gt) is wrapped in $not
+		if len(v) > 0 && len(v[0].Key) > 0 && v[0].Key[0:1] == "$" {
+			return bson.D{bson.E{Key: "$not", Value: v}}
+		}
+	}
+
+	// a value is negated with $ne
+	return bson.D{bson.E{Key: "$ne", Value: v}}
+}
+
+// combineClauses combines the clauses for multiple fields using the provided
+// logical operator... with $and, the field clauses are merged into a single
+// document while a logical operator that is present in more than one field
+// clause (i.e. the $or for multiple values of two fields) is added as its own
+// element of a top level $and so that the clauses of each field are never
+// merged. With $or and
+// $nor, the clauses are added to a top level array.
+func combineClauses(clauses []bson.M, lo LogicalOperator) bson.M {
+	switch lo {
+	case Or, Nor, Not:
+		if len(clauses) == 0 {
+			return bson.M{}
+		}
+
+		// a single clause does not require an $or
+		if lo == Or && len(clauses) == 1 {
+			return clauses[0]
+		}
+
+		// $not is not valid at the top level, so use $nor (none of the clauses)
+		if lo == Not {
+			lo = Nor
+		}
+
+		a := bson.A{}
+		for _, c := range clauses {
+			a = append(a, c)
+		}
+
+		return bson.M{lo.String(): a}
+	}
+
+	// a logical operator that is only present in a single clause remains at the
+	// top level of the filter
+	count := map[string]int{}
+	for _, c := range clauses {
+		for k := range c {
+			count[k]++
+		}
+	}
+
+	filter := bson.M{}
+	and := bson.A{}
+	for _, c := range clauses {
+		for _, k := range sortedKeys(c) {
+			switch k {
+			case And.String():
+				// each element of an $and is already its own clause
+				if a, ok := c[k].(bson.A); ok {
+					and = append(and, a...)
+					continue
+				}
+			case Nor.String(), Or.String():
+				if count[k] > 1 {
+					and = append(and, bson.M{k: c[k]})
+					continue
+				}
+			}
+
+			filter = combine(filter, bson.M{k: c[k]})
+		}
+	}
+
+	if len(and) > 0 {
+		filter = combine(filter, bson.M{And.String(): and})
+	}
+
+	return filter
 }
 
 func combine(a bson.M, b bson.M) bson.M {
@@ -652,8 +1159,25 @@ func combine(a bson.M, b bson.M) bson.M {
 				}
 			}
 
-			// check if existing value is a bson.A
-			if eva, ok := ev.(bson.A); ok {
+			// check if both values are operator expressions for the same field
+			// (i.e. filter[price][gte]=10&filter[price][lt]=20)
+			if evd, ok := ev.(bson.D); ok && isOperatorExpression(evd) {
+				if vd, ok := v.(bson.D); ok && isOperatorExpression(vd) {
+					// an operator that is provided more than once for the same
+					// field is nested within $and so that neither is lost
+					if hasOperator(evd, vd) {
+						a = combine(a, bson.M{And.String(): bson.A{bson.M{k: v}}})
+						continue
+					}
+
+					a[k] = append(append(bson.D{}, evd...), vd...)
+					continue
+				}
+			}
+
+			// check if existing value is a bson.A for $and or $nor (appending the
+			// members of an $or would match documents for either clause)
+			if eva, ok := ev.(bson.A); ok && (k == And.String() || k == Nor.String()) {
 				// check if new value is a bson.A
 				if va, ok := v.(bson.A); ok {
 					// combine the two bson.A values by appending each element
@@ -662,6 +1186,13 @@ func combine(a bson.M, b bson.M) bson.M {
 					continue
 				}
 			}
+
+			// any other value for the same field (i.e. filter[price]=10 and
+			// filter[price][gte]=5) is nested within $and so that neither is lost
+			if k != And.String() {
+				a = combine(a, bson.M{And.String(): bson.A{bson.M{k: v}}})
+				continue
+			}
 		}
 
 		a[k] = v
@@ -669,3 +1200,28 @@ func combine(a bson.M, b bson.M) bson.M {
 
 	return a
 }
+
+// isOperatorExpression determines if every key of the document is a query
+// operator (i.e. { $gte: 10, $lt: 20 })
+func isOperatorExpression(d bson.D) bool {
+	for _, e := range d {
+		if len(e.Key) == 0 || e.Key[0:1] != "$" {
+			return false
+		}
+	}
+
+	return len(d) > 0
+}
+
+// hasOperator determines if any operator in b is already present in a
+func hasOperator(a bson.D, b bson.D) bool {
+	for _, be := range b {
+		for _, ae := range a {
+			if ae.Key == be.Key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,316 @@
+package querybuilder
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	queryoptions "go.jtlabs.io/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	cursorAfter  = "page[after]"
+	cursorBefore = "page[before]"
+)
+
+// Cursor holds the opaque page[after] and page[before] tokens provided by a
+// client when using keyset (cursor) pagination. Tokens are created with
+// QueryBuilder.CursorToken and contain the sort key values of a document.
+type Cursor struct {
+	After  string
+	Before string
+}
+
+type cursorPayload struct {
+	Keys       []string `bson:"k"`
+	Directions []int32  `bson:"d"`
+	Values     bson.A   `bson:"v"`
+}
+
+// CursorFromQuerystring removes the page[after] and page[before] tokens from
+// the provided querystring and returns the remaining querystring along with the
+// Cursor. This is necessary because the query options parser only supports
+// numeric page values.
+//
+//	qs, cur, err := CursorFromQuerystring(r.URL.RawQuery)
+//	if err != nil {
+//		return err
+//	}
+//
+//	opt, err := queryoptions.FromQuerystring(qs)
+//	if err != nil {
+//		return err
+//	}
+//
+//	qb := builder.WithOptions(QueryBuilderOptions().SetCursor(cur))
+//	filter, err := qb.Filter(opt)
+func CursorFromQuerystring(qs string) (string, Cursor, error) {
+	cur := Cursor{}
+	terms := []string{}
+
+	for _, term := range strings.Split(qs, "&") {
+		if term == "" {
+			continue
+		}
+
+		kv := strings.SplitN(term, "=", 2)
+		key, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			return qs, cur, err
+		}
+
+		// retain any terms that are not cursor tokens
+		if key != cursorAfter && key != cursorBefore {
+			terms = append(terms, term)
+			continue
+		}
+
+		var value string
+		if len(kv) > 1 {
+			if value, err = url.QueryUnescape(kv[1]); err != nil {
+				return qs, cur, err
+			}
+		}
+
+		if key == cursorAfter {
+			cur.After = value
+			continue
+		}
+
+		cur.Before = value
+	}
+
+	return strings.Join(terms, "&"), cur, nil
+}
+
+// CursorToken creates an opaque, tamper-evident token that encodes the values
+// of the sort keys (as specified in the query options, including any sort
+// tiebreaker) for the provided document. The token for the last document in a
+// page should be provided by the client as page[after] to retrieve the next
+// page, and the token for the first document as page[before] to retrieve the
+// previous page. A cursor secret must be set with SetCursorSecret.
+func (qb QueryBuilder) CursorToken(qo queryoptions.Options, doc any) (string, error) {
+	secret := qb.opts.secret()
+	if len(secret) == 0 {
+		return "", fmt.Errorf("a cursor secret is required to create cursor tokens")
+	}
+
+	sort, err := qb.keysetSort(qo.Sort, qo.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+
+	// capture the value of each sort key from the document
+	payload := cursorPayload{}
+	for _, e := range sort {
+		rv, err := bson.Raw(raw).LookupErr(strings.Split(e.Key, ".")...)
+		if err != nil {
+			return "", fmt.Errorf("document is missing sort field %s", e.Key)
+		}
+
+		payload.Keys = append(payload.Keys, e.Key)
+		payload.Directions = append(payload.Directions, int32(e.Value.(int)))
+		payload.Values = append(payload.Values, rv)
+	}
+
+	b, err := bson.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString(b),
+		base64.RawURLEncoding.EncodeToString(signCursor(secret, b)),
+	}, "."), nil
+}
+
+// keysetSort returns the sort for keyset pagination... a unique key is always
+// last so that documents sharing the values of the requested sort keys (i.e.
+// sort=lastName) are not skipped, which is the sort tiebreaker when one is
+// configured and _id otherwise
+func (qb QueryBuilder) keysetSort(fields []string, filter map[string][]string) (bson.D, error) {
+	sort, err := qb.sortKeys(fields, filter)
+
+	// the relevance score of a text search can not be used as a cursor (which
+	// is reported by FindOptions)
+	for i := 0; i < len(sort); i++ {
+		if _, ok := sort[i].Value.(int); !ok {
+			sort = append(sort[:i], sort[i+1:]...)
+			i--
+		}
+	}
+
+	// sortKeys appends the configured tiebreaker
+	if qb.opts.tiebreaker() != "" {
+		return sort, err
+	}
+
+	for _, e := range sort {
+		if e.Key == "_id" {
+			return sort, err
+		}
+	}
+
+	return append(sort, bson.E{Key: "_id", Value: 1}), err
+}
+
+// keysetFilter decodes any cursor tokens set on the QueryBuilder options into
+// range clauses that select the documents after (or before) the cursor
+func (qb QueryBuilder) keysetFilter(fields []string, filter map[string][]string) (bson.M, error) {
+	cur := qb.opts.cursorTokens()
+	if cur == nil {
+		return nil, nil
+	}
+
+	// unknown sort fields are reported by FindOptions
+	sort, _ := qb.keysetSort(fields, filter)
+	errs := ValidationErrors{}
+	clauses := bson.A{}
+
+	for _, t := range []struct {
+		name  string
+		token string
+		after bool
+	}{
+		{cursorAfter, cur.After, true},
+		{cursorBefore, cur.Before, false},
+	} {
+		if t.token == "" {
+			continue
+		}
+
+		values, err := decodeCursor(qb.opts.secret(), t.token, sort)
+		if err != nil {
+			errs.add(&InvalidValueError{
+				Field:  t.name,
+				Value:  t.token,
+				Reason: err.Error(),
+			})
+			continue
+		}
+
+		clauses = append(clauses, keysetClause(sort, values, t.after))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if len(clauses) == 0 {
+		return nil, nil
+	}
+
+	return bson.M{"$and": clauses}, nil
+}
+
+// keysetClause builds the $or clause for the provided sort keys and values, i.e.
+// for a sort of {a: 1, b: -1} after the values [x, y]:
+// {$or: [{a: {$gt: x}}, {a: x, b: {$lt: y}}, {a: x, b: null}]}
+//
+// null sorts before every other value, so a comparison with null (which
+// matches nothing) is never built... nothing is before a null cursor value,
+// everything that is not null is after it and null is before any other value
+func keysetClause(sort bson.D, values bson.A, after bool) bson.M {
+	or := bson.A{}
+
+	for i, e := range sort {
+		// every preceding key must be equal to the cursor value
+		prefix := func(c bson.E) bson.D {
+			clause := bson.D{}
+			for j := 0; j < i; j++ {
+				clause = append(clause, bson.E{Key: sort[j].Key, Value: values[j]})
+			}
+
+			return append(clause, c)
+		}
+
+		// the current key must be beyond the cursor value in the sort direction
+		oper := "$lt"
+		if (e.Value.(int) > 0) == after {
+			oper = "$gt"
+		}
+
+		switch {
+		case values[i] == nil && oper == "$lt":
+			continue
+		case values[i] == nil:
+			or = append(or, prefix(bson.E{Key: e.Key, Value: bson.D{bson.E{Key: "$ne", Value: nil}}}))
+		default:
+			or = append(or, prefix(bson.E{Key: e.Key, Value: bson.D{bson.E{Key: oper, Value: values[i]}}}))
+
+			if oper == "$lt" {
+				or = append(or, prefix(bson.E{Key: e.Key, Value: nil}))
+			}
+		}
+	}
+
+	// no document is beyond the cursor (a $nor of an empty document matches
+	// nothing, while an empty $or is not valid)
+	if len(or) == 0 {
+		return bson.M{"$nor": bson.A{bson.M{}}}
+	}
+
+	return bson.M{"$or": or}
+}
+
+func decodeCursor(secret []byte, token string, sort bson.D) (bson.A, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("a cursor secret is required to read cursor tokens")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+
+	// ensure the cursor has not been tampered with
+	if !hmac.Equal(sig, signCursor(secret, b)) {
+		return nil, fmt.Errorf("cursor signature is invalid")
+	}
+
+	payload := cursorPayload{}
+	if err := bson.Unmarshal(b, &payload); err != nil {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+
+	// ensure the cursor was created for the same sort
+	if len(payload.Keys) != len(sort) ||
+		len(payload.Directions) != len(sort) ||
+		len(payload.Values) != len(sort) {
+		return nil, fmt.Errorf("cursor does not match the requested sort")
+	}
+
+	for i, e := range sort {
+		if payload.Keys[i] != e.Key || int(payload.Directions[i]) != e.Value.(int) {
+			return nil, fmt.Errorf("cursor does not match the requested sort")
+		}
+	}
+
+	return payload.Values, nil
+}
+
+func signCursor(secret []byte, b []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(b)
+
+	return mac.Sum(nil)
+}
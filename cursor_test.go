@@ -0,0 +1,284 @@
+package querybuilder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	queryoptions "go.jtlabs.io/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCursorFromQuerystring(t *testing.T) {
+	tests := []struct {
+		name    string
+		qs      string
+		wantQS  string
+		want    Cursor
+		wantErr bool
+	}{
+		{
+			name:   "should leave a querystring without cursor tokens as is",
+			qs:     "filter[name]=test&page[limit]=10",
+			wantQS: "filter[name]=test&page[limit]=10",
+			want:   Cursor{},
+		},
+		{
+			name:   "should remove page[after] and page[before] tokens",
+			qs:     "page[after]=abc.def&filter[name]=test&page%5Bbefore%5D=ghi.jkl&page[limit]=10",
+			wantQS: "filter[name]=test&page[limit]=10",
+			want: Cursor{
+				After:  "abc.def",
+				Before: "ghi.jkl",
+			},
+		},
+		{
+			name:    "should error when the querystring can not be unescaped",
+			qs:      "page[after]=%zz",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qs, cur, err := CursorFromQuerystring(tt.qs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CursorFromQuerystring() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if qs != tt.wantQS {
+				t.Errorf("CursorFromQuerystring() qs = %s, want %s", qs, tt.wantQS)
+			}
+
+			if !reflect.DeepEqual(cur, tt.want) {
+				t.Errorf("CursorFromQuerystring() cursor = %v, want %v", cur, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Cursor(t *testing.T) {
+	type doc struct {
+		ID       int32  `bson:"_id"`
+		LastName string `bson:"lastName"`
+		Age      int32  `bson:"age"`
+	}
+
+	qb := NewQueryBuilder("test", nil).WithOptions(
+		QueryBuilderOptions().SetCursorSecret([]byte("secret")).SetSortTiebreaker("_id"))
+	qo := queryoptions.Options{
+		Filter: map[string][]string{},
+		Page:   map[string]int{"limit": 10, "offset": 20},
+		Sort:   []string{"lastName", "-age"},
+	}
+
+	token, err := qb.CursorToken(qo, doc{ID: 7, LastName: "Smith", Age: 42})
+	if err != nil {
+		t.Fatalf("QueryBuilder.CursorToken() error = %v", err)
+	}
+
+	// the documents after the cursor
+	after := qb.WithOptions(QueryBuilderOptions().SetCursor(Cursor{After: token}))
+	got, err := after.Filter(qo)
+	if err != nil {
+		t.Fatalf("QueryBuilder.Filter() error = %v", err)
+	}
+
+	want := bson.M{
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.D{
+					{Key: "lastName", Value: bson.D{{Key: "$gt", Value: "Smith"}}},
+				},
+				bson.D{
+					{Key: "lastName", Value: "Smith"},
+					{Key: "age", Value: bson.D{{Key: "$lt", Value: int32(42)}}},
+				},
+				bson.D{
+					{Key: "lastName", Value: "Smith"},
+					{Key: "age", Value: nil},
+				},
+				bson.D{
+					{Key: "lastName", Value: "Smith"},
+					{Key: "age", Value: int32(42)},
+					{Key: "_id", Value: bson.D{{Key: "$gt", Value: int32(7)}}},
+				},
+			}},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, want)
+	}
+
+	fo, err := after.FindOptions(qo)
+	if err != nil {
+		t.Fatalf("QueryBuilder.FindOptions() error = %v", err)
+	}
+
+	wantSort := bson.D{{Key: "lastName", Value: 1}, {Key: "age", Value: -1}, {Key: "_id", Value: 1}}
+	if fo.Skip != nil || *fo.Limit != 10 || !reflect.DeepEqual(fo.Sort, wantSort) {
+		t.Errorf("QueryBuilder.FindOptions() = skip %v, limit %v, sort %v", fo.Skip, *fo.Limit, fo.Sort)
+	}
+
+	// the documents before the cursor
+	before := qb.WithOptions(QueryBuilderOptions().SetCursor(Cursor{Before: token}))
+	got, err = before.Filter(qo)
+	if err != nil {
+		t.Fatalf("QueryBuilder.Filter() error = %v", err)
+	}
+
+	or := got["$and"].(bson.A)[0].(bson.M)["$or"].(bson.A)
+	if !reflect.DeepEqual(or[0], bson.D{{Key: "lastName", Value: bson.D{{Key: "$lt", Value: "Smith"}}}}) {
+		t.Errorf("QueryBuilder.Filter() before = %v", or[0])
+	}
+
+	fo, err = before.FindOptions(qo)
+	if err != nil {
+		t.Fatalf("QueryBuilder.FindOptions() error = %v", err)
+	}
+
+	wantSort = bson.D{{Key: "lastName", Value: -1}, {Key: "age", Value: 1}, {Key: "_id", Value: -1}}
+	if !reflect.DeepEqual(fo.Sort, wantSort) {
+		t.Errorf("QueryBuilder.FindOptions() sort = %v, want %v", fo.Sort, wantSort)
+	}
+
+	// a tampered token is rejected
+	parts := strings.Split(token, ".")
+	tampered := strings.Join([]string{parts[0] + "A", parts[1]}, ".")
+	if _, err := qb.WithOptions(QueryBuilderOptions().SetCursor(Cursor{After: tampered})).Filter(qo); err == nil {
+		t.Error("QueryBuilder.Filter() expected an error for a tampered cursor")
+	}
+
+	// a token created for a different sort is rejected
+	qo.Sort = []string{"age"}
+	if _, err := after.Filter(qo); err == nil {
+		t.Error("QueryBuilder.Filter() expected an error for a cursor with a different sort")
+	}
+
+	// tokens can not be created without a secret
+	if _, err := NewQueryBuilder("test", nil).CursorToken(qo, doc{}); err == nil {
+		t.Error("QueryBuilder.CursorToken() expected an error without a cursor secret")
+	}
+}
+
+func TestQueryBuilder_Cursor_DefaultTiebreaker(t *testing.T) {
+	type doc struct {
+		ID       int32  `bson:"_id"`
+		LastName string `bson:"lastName"`
+	}
+
+	qb := NewQueryBuilder("test", nil).WithOptions(QueryBuilderOptions().SetCursorSecret([]byte("secret")))
+	qo := queryoptions.Options{Sort: []string{"lastName"}}
+
+	token, err := qb.CursorToken(qo, doc{ID: 7, LastName: "Smith"})
+	if err != nil {
+		t.Fatalf("QueryBuilder.CursorToken() error = %v", err)
+	}
+
+	// documents sharing the last name of the cursor are not skipped
+	after := qb.WithOptions(QueryBuilderOptions().SetCursor(Cursor{After: token}))
+	got, err := after.Filter(qo)
+	if err != nil {
+		t.Fatalf("QueryBuilder.Filter() error = %v", err)
+	}
+
+	want := bson.M{
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.D{
+					{Key: "lastName", Value: bson.D{{Key: "$gt", Value: "Smith"}}},
+				},
+				bson.D{
+					{Key: "lastName", Value: "Smith"},
+					{Key: "_id", Value: bson.D{{Key: "$gt", Value: int32(7)}}},
+				},
+			}},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, want)
+	}
+
+	fo, err := after.FindOptions(qo)
+	if err != nil {
+		t.Fatalf("QueryBuilder.FindOptions() error = %v", err)
+	}
+
+	wantSort := bson.D{{Key: "lastName", Value: 1}, {Key: "_id", Value: 1}}
+	if !reflect.DeepEqual(fo.Sort, wantSort) {
+		t.Errorf("QueryBuilder.FindOptions() sort = %v, want %v", fo.Sort, wantSort)
+	}
+}
+
+func TestQueryBuilder_Cursor_NullValues(t *testing.T) {
+	type doc struct {
+		ID       int32   `bson:"_id"`
+		LastName *string `bson:"lastName"`
+	}
+
+	qb := NewQueryBuilder("test", nil).WithOptions(QueryBuilderOptions().SetCursorSecret([]byte("secret")))
+	qo := queryoptions.Options{Sort: []string{"lastName"}}
+
+	token, err := qb.CursorToken(qo, doc{ID: 7})
+	if err != nil {
+		t.Fatalf("QueryBuilder.CursorToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		cursor Cursor
+		want   bson.A
+	}{
+		{
+			name:   "should match every value that is not null after a null cursor value",
+			cursor: Cursor{After: token},
+			want: bson.A{
+				bson.D{
+					{Key: "lastName", Value: bson.D{{Key: "$ne", Value: nil}}},
+				},
+				bson.D{
+					{Key: "lastName", Value: nil},
+					{Key: "_id", Value: bson.D{{Key: "$gt", Value: int32(7)}}},
+				},
+			},
+		},
+		{
+			name:   "should not compare with null before a null cursor value",
+			cursor: Cursor{Before: token},
+			want: bson.A{
+				bson.D{
+					{Key: "lastName", Value: nil},
+					{Key: "_id", Value: bson.D{{Key: "$lt", Value: int32(7)}}},
+				},
+				bson.D{
+					{Key: "lastName", Value: nil},
+					{Key: "_id", Value: nil},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := qb.WithOptions(QueryBuilderOptions().SetCursor(tt.cursor)).Filter(qo)
+			if err != nil {
+				t.Fatalf("QueryBuilder.Filter() error = %v", err)
+			}
+
+			want := bson.M{"$and": bson.A{bson.M{"$or": tt.want}}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, want)
+			}
+		})
+	}
+
+	// nothing is before a null cursor value of a single sort key
+	if got := keysetClause(bson.D{{Key: "lastName", Value: 1}}, bson.A{nil}, false); !reflect.DeepEqual(got, bson.M{"$nor": bson.A{bson.M{}}}) {
+		t.Errorf("keysetClause() = %v", got)
+	}
+}
@@ -0,0 +1,301 @@
+package querybuilder
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var reDateKeyword = regexp.MustCompile(`^(?i:(now|today|yesterday|tomorrow|startofweek|startofmonth|startofyear))(?:([+\- ])(\d+)([smhdwMy]))?$`)
+
+// dateParser parses date expressions (i.e. 2024-05-10T12:00:00Z, 2024-05 or
+// now-7d) relative to the current time of the clock... dates without a time
+// zone are interpreted in the location of the parser (UTC by default)
+type dateParser struct {
+	now time.Time
+	loc *time.Location
+}
+
+// dateValue is a parsed date expression... a date with a precision of a day, a
+// month or a year (i.e. 2024-05) is a range from start (inclusive) to end
+// (exclusive), otherwise end is nil and the value is an instant in time. The
+// start and end are a time.Time for date fields and a primitive.Timestamp for
+// timestamp fields. A timestamp with a precision of a second (i.e. 1700000000)
+// includes the last increment of the second, which is used for $gt and $lte.
+type dateValue struct {
+	start any
+	end   any
+	last  any
+}
+
+// newDateValue creates a date value in UTC... a zero end is an instant in time
+func newDateValue(start time.Time, end time.Time) dateValue {
+	if end.IsZero() {
+		return dateValue{start: start.UTC()}
+	}
+
+	return dateValue{start: start.UTC(), end: end.UTC()}
+}
+
+// parse parses a date expression, which may be any of the following:
+// * an RFC3339 formatted date (or a local time without the zone, such as
+// 2006-01-02T15:04:05)
+// * a date with a day (2006-01-02 or 2006/01/02), month (2006-01 or 2006/01)
+// or year (2006) precision
+// * now, today, yesterday, tomorrow, startOfWeek, startOfMonth or startOfYear
+// with an optional offset (i.e. now-7d or today+1w) in seconds (s), minutes (m),
+// hours (h), days (d), weeks (w), months (M) or years (y)... a space is treated
+// as + because an unencoded + in a querystring is decoded as a space
+func (dp dateParser) parse(value string) (dateValue, error) {
+	if m := reDateKeyword.FindStringSubmatch(value); m != nil {
+		return dp.keyword(m)
+	}
+
+	if dv, err := time.Parse(time.RFC3339, value); err == nil {
+		return newDateValue(dv, time.Time{}), nil
+	}
+
+	if dv, err := time.ParseInLocation("2006-01-02T15:04:05", value, dp.location()); err == nil {
+		return newDateValue(dv, time.Time{}), nil
+	}
+
+	// dates are expanded to include the whole day, month or year
+	precisions := []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006/01/02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006/01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	}
+
+	for _, p := range precisions {
+		if len(value) != len(p.layout) {
+			continue
+		}
+
+		if dv, err := time.ParseInLocation(p.layout, value, dp.location()); err == nil {
+			return newDateValue(dv, p.next(dv)), nil
+		}
+	}
+
+	return dateValue{}, fmt.Errorf(
+		"expected an RFC3339, 2006-01-02, 2006/01/02, 2006-01 or 2006 formatted date or a relative date (i.e. now-7d, today, startOfMonth)")
+}
+
+// location returns the location used for dates without a time zone
+func (dp dateParser) location() *time.Location {
+	if dp.loc == nil {
+		return time.UTC
+	}
+
+	return dp.loc
+}
+
+// keyword resolves a relative date keyword along with any offset
+func (dp dateParser) keyword(m []string) (dateValue, error) {
+	now := dp.now.In(dp.location())
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, dp.location())
+
+	var start, end time.Time
+	switch strings.ToLower(m[1]) {
+	case "now":
+		start = now
+	case "today":
+		start, end = day, day.AddDate(0, 0, 1)
+	case "yesterday":
+		start, end = day.AddDate(0, 0, -1), day
+	case "tomorrow":
+		start, end = day.AddDate(0, 0, 1), day.AddDate(0, 0, 2)
+	case "startofweek":
+		// weeks start on Monday
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "startofmonth":
+		start = day.AddDate(0, 0, 1-day.Day())
+	case "startofyear":
+		start = day.AddDate(0, 0, 1-day.YearDay())
+	}
+
+	// no offset
+	if m[2] == "" {
+		return newDateValue(start, end), nil
+	}
+
+	n, err := strconv.Atoi(m[3])
+	if err != nil {
+		return dateValue{}, err
+	}
+
+	if m[2] == "-" {
+		n = -n
+	}
+
+	offset := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return t
+		}
+
+		switch m[4] {
+		case "s":
+			return t.Add(time.Duration(n) * time.Second)
+		case "m":
+			return t.Add(time.Duration(n) * time.Minute)
+		case "h":
+			return t.Add(time.Duration(n) * time.Hour)
+		case "d":
+			return t.AddDate(0, 0, n)
+		case "w":
+			return t.AddDate(0, 0, n*7)
+		case "M":
+			return t.AddDate(0, n, 0)
+		}
+
+		return t.AddDate(n, 0, 0)
+	}
+
+	return newDateValue(offset(start), offset(end)), nil
+}
+
+// isRange determines if the date value is a range rather than an instant
+func (dv dateValue) isRange() bool {
+	return dv.end != nil
+}
+
+// bound returns the comparison for the operator... when the date value is a
+// range, the operator is adjusted so that the whole range is considered (i.e.
+// >2024-05 is $gte 2024-06-01 and <=2024-05 is $lt 2024-06-01)
+func (dv dateValue) bound(oper string) bson.E {
+	if !dv.isRange() {
+		if dv.last != nil && (oper == "$gt" || oper == "$lte") {
+			return bson.E{Key: oper, Value: dv.last}
+		}
+
+		return bson.E{Key: oper, Value: dv.start}
+	}
+
+	switch oper {
+	case "$gt":
+		return bson.E{Key: "$gte", Value: dv.end}
+	case "$lte":
+		return bson.E{Key: "$lt", Value: dv.end}
+	case "$lt":
+		return bson.E{Key: "$lt", Value: dv.start}
+	}
+
+	return bson.E{Key: "$gte", Value: dv.start}
+}
+
+// expression returns the operator expression for a date value that is a range
+// for equality ([start, end)) or inequality ($not [start, end))
+func (dv dateValue) expression(oper string) bson.D {
+	expr := bson.D{
+		bson.E{Key: "$gte", Value: dv.start},
+		bson.E{Key: "$lt", Value: dv.end},
+	}
+
+	if oper == "$ne" {
+		return bson.D{bson.E{Key: "$not", Value: expr}}
+	}
+
+	return expr
+}
+
+// parseTimestamp parses a timestamp value, which may be any of the following:
+// * a T,I pair of seconds since the epoch and an increment (i.e. (1700000000,1))
+// * seconds since the epoch (i.e. 1700000000)
+// * any date expression supported by the date parser (i.e. an RFC3339 date,
+// 2024-05 or now-7d), converted to seconds since the epoch with an increment
+// of 0
+//
+// A value without an increment includes every increment within the second for
+// $gt and $lte (i.e. <=1700000000 is $lte 1700000000,4294967295), and dates must
+// be within the range of a uint32 of seconds since the epoch (1970 to 2106).
+func parseTimestamp(value string, dp dateParser) (dateValue, error) {
+	v := strings.TrimSuffix(strings.TrimPrefix(value, "("), ")")
+
+	if t, i, ok := strings.Cut(v, ","); ok {
+		tv, err := strconv.ParseUint(strings.TrimSpace(t), 10, 32)
+		if err != nil {
+			return dateValue{}, fmt.Errorf("expected the seconds of the T,I pair to be an unsigned 32-bit integer")
+		}
+
+		iv, err := strconv.ParseUint(strings.TrimSpace(i), 10, 32)
+		if err != nil {
+			return dateValue{}, fmt.Errorf("expected the increment of the T,I pair to be an unsigned 32-bit integer")
+		}
+
+		return dateValue{start: primitive.Timestamp{T: uint32(tv), I: uint32(iv)}}, nil
+	}
+
+	// seconds since the epoch
+	if tv, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return dateValue{
+			start: primitive.Timestamp{T: uint32(tv)},
+			last:  primitive.Timestamp{T: uint32(tv), I: math.MaxUint32},
+		}, nil
+	}
+
+	dv, err := dp.parse(value)
+	if err != nil {
+		return dateValue{}, fmt.Errorf("expected a T,I pair, seconds since the epoch or an RFC3339 formatted date")
+	}
+
+	ts := func(v any) (any, error) {
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, nil
+		}
+
+		if t.Unix() < 0 || t.Unix() > math.MaxUint32 {
+			return nil, fmt.Errorf("expected a date between 1970-01-01 and 2106-02-07")
+		}
+
+		return primitive.Timestamp{T: uint32(t.Unix())}, nil
+	}
+
+	start, err := ts(dv.start)
+	if err != nil {
+		return dateValue{}, err
+	}
+
+	end, err := ts(dv.end)
+	if err != nil {
+		return dateValue{}, err
+	}
+
+	// an instant has a precision of a second
+	if end == nil {
+		t := start.(primitive.Timestamp)
+		return dateValue{start: t, last: primitive.Timestamp{T: t.T, I: math.MaxUint32}}, nil
+	}
+
+	return dateValue{start: start, end: end}, nil
+}
+
+// joinTimestampPairs joins the T,I pairs within parentheses that have been
+// split into separate values by the querystring parser (i.e. (1700000000 and
+// 1) back into a single value
+func joinTimestampPairs(values []string) []string {
+	joined := make([]string, 0, len(values))
+
+	for i := 0; i < len(values); i++ {
+		v := values[i]
+
+		for strings.Count(v, "(") > strings.Count(v, ")") && i+1 < len(values) {
+			i++
+			v = fmt.Sprintf("%s,%s", v, values[i])
+		}
+
+		joined = append(joined, v)
+	}
+
+	return joined
+}
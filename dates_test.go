@@ -0,0 +1,116 @@
+package querybuilder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_dateParser_parse(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, time.May, 15, 12, 30, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		loc     *time.Location
+		want    dateValue
+		wantErr bool
+	}{
+		{
+			name:  "should parse an RFC3339 date as an instant",
+			value: "2024-05-10T08:00:00Z",
+			want:  dateValue{start: time.Date(2024, time.May, 10, 8, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "should parse a day as a range",
+			value: "2024-05-10",
+			want:  dateValue{start: day(2024, time.May, 10), end: day(2024, time.May, 11)},
+		},
+		{
+			name:  "should parse a day as a range within the location",
+			value: "2024/05/10",
+			loc:   time.FixedZone("UTC+10", 10*60*60),
+			want: dateValue{
+				start: time.Date(2024, time.May, 9, 14, 0, 0, 0, time.UTC),
+				end:   time.Date(2024, time.May, 10, 14, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "should parse a local time within the location",
+			value: "2024-05-10T08:00:00",
+			loc:   time.FixedZone("UTC+10", 10*60*60),
+			want:  dateValue{start: time.Date(2024, time.May, 9, 22, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "should resolve a relative day within the location",
+			value: "yesterday",
+			loc:   time.FixedZone("UTC+12", 12*60*60),
+			want: dateValue{
+				start: time.Date(2024, time.May, 14, 12, 0, 0, 0, time.UTC),
+				end:   time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "should parse a month as a range",
+			value: "2024/02",
+			want:  dateValue{start: day(2024, time.February, 1), end: day(2024, time.March, 1)},
+		},
+		{
+			name:  "should parse a year as a range",
+			value: "2023",
+			want:  dateValue{start: day(2023, time.January, 1), end: day(2024, time.January, 1)},
+		},
+		{
+			name:  "should resolve now with an offset",
+			value: "now-90m",
+			want:  dateValue{start: time.Date(2024, time.May, 15, 11, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "should resolve a relative day as a range",
+			value: "Tomorrow",
+			want:  dateValue{start: day(2024, time.May, 16), end: day(2024, time.May, 17)},
+		},
+		{
+			name:  "should treat a space as a positive offset",
+			value: "today 1w",
+			want:  dateValue{start: day(2024, time.May, 22), end: day(2024, time.May, 23)},
+		},
+		{
+			name:  "should resolve the start of the week with an offset",
+			value: "startOfWeek-1y",
+			want:  dateValue{start: day(2023, time.May, 13)},
+		},
+		{
+			name:  "should resolve the start of the month",
+			value: "startOfMonth",
+			want:  dateValue{start: day(2024, time.May, 1)},
+		},
+		{
+			name:    "should error for an unknown unit",
+			value:   "now-7x",
+			wantErr: true,
+		},
+		{
+			name:    "should error for an invalid date",
+			value:   "2024-02-30",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dateParser{now: now, loc: tt.loc}.parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("dateParser.parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dateParser.parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
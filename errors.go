@@ -0,0 +1,148 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownFieldError is returned when a field that is referenced in query options
+// (filter, fields or sort) or in an update document does not exist within the
+// schema of the collection and strict validation is enabled.
+type UnknownFieldError struct {
+	Collection string
+	Field      string
+	Value      string
+	Reason     string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("field %s does not exist in collection %s", e.Field, e.Collection)
+}
+
+// InvalidValueError is returned when a value provided for a field can not be
+// parsed as the bsonType of the field defined in the schema (i.e. a value of
+// "abc" provided for an int field).
+type InvalidValueError struct {
+	Collection string
+	Field      string
+	Value      string
+	BSONType   string
+	Reason     string
+}
+
+func (e *InvalidValueError) Error() string {
+	if e.BSONType == "" {
+		return fmt.Sprintf(
+			"invalid value %s for field %s in collection %s: %s",
+			e.Value,
+			e.Field,
+			e.Collection,
+			e.Reason)
+	}
+
+	return fmt.Sprintf(
+		"invalid value %s for %s field %s in collection %s: %s",
+		e.Value,
+		e.BSONType,
+		e.Field,
+		e.Collection,
+		e.Reason)
+}
+
+// UnsupportedOperatorError is returned when an operator is provided for a field
+// whose bsonType does not support the operator (i.e. a > comparison for a bool
+// field).
+type UnsupportedOperatorError struct {
+	Collection string
+	Field      string
+	Value      string
+	Operator   string
+	Reason     string
+}
+
+func (e *UnsupportedOperatorError) Error() string {
+	return fmt.Sprintf(
+		"unsupported operator %s for field %s in collection %s: %s",
+		e.Operator,
+		e.Field,
+		e.Collection,
+		e.Reason)
+}
+
+// SchemaError is returned by NewQueryBuilderE and NewUpdateBuilderE when a
+// schema can not be parsed (i.e. a bsonType that is not a string or a list of
+// strings). Path is the JSON path of the problem within the schema (i.e.
+// $.$jsonSchema.properties.name.bsonType).
+type SchemaError struct {
+	Collection string
+	Path       string
+	Reason     string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("invalid schema for collection %s at %s: %s", e.Collection, e.Path, e.Reason)
+}
+
+// ValidationErrors is a collection of every validation problem that was found
+// while building a filter, options or an update document. Each underlying error
+// can be inspected with errors.As.
+type ValidationErrors []error
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(ve))
+	for _, err := range ve {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the underlying errors so that errors.Is and errors.As are able
+// to inspect each problem that was found
+func (ve ValidationErrors) Unwrap() []error {
+	return ve
+}
+
+// add appends an error to the collection, flattening any nested ValidationErrors
+func (ve *ValidationErrors) add(err error) {
+	if err == nil {
+		return
+	}
+
+	if nested, ok := err.(ValidationErrors); ok {
+		*ve = append(*ve, nested...)
+		return
+	}
+
+	*ve = append(*ve, err)
+}
+
+// err returns nil when no errors have been collected (avoids returning a non-nil
+// error interface that wraps an empty collection)
+func (ve ValidationErrors) err() error {
+	if len(ve) == 0 {
+		return nil
+	}
+
+	return ve
+}
+
+// withCollection sets the collection name on any of the validation error types
+func withCollection(err error, collection string) error {
+	switch e := err.(type) {
+	case *UnknownFieldError:
+		e.Collection = collection
+	case *InvalidValueError:
+		e.Collection = collection
+	case *UnsupportedOperatorError:
+		e.Collection = collection
+	case *SchemaError:
+		e.Collection = collection
+	case ValidationErrors:
+		for _, ne := range e {
+			withCollection(ne, collection)
+		}
+	}
+
+	return err
+}
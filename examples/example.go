@@ -126,7 +126,7 @@ func getOrSetThings(w http.ResponseWriter, r *http.Request) {
 	// build a bson.M filter for the Find based on queryoptions filters
 	filter, err := queryBuilder.Filter(opt)
 	if err != nil {
-		// NOTE: will only error when strictValidation is true
+		// NOTE: errors when a value is invalid or when strictValidation is true
 		fmt.Fprint(w, err)
 		return
 	}
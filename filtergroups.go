@@ -0,0 +1,177 @@
+package querybuilder
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// groupOperators are the logical operators that can be used to group filters
+// in the querystring (i.e. filter[or][0][status]=open)
+var groupOperators = map[string]LogicalOperator{
+	"and": And,
+	"nor": Nor,
+	"or":  Or,
+}
+
+// filterKeyPath splits a filter key into each of the bracketed segments... the
+// query options parser provides the key within the outer filter[...] brackets,
+// so filter[or][0][status] results in a key of or][0][status
+func filterKeyPath(key string) []string {
+	return strings.FieldsFunc(key, func(r rune) bool {
+		return r == '[' || r == ']'
+	})
+}
+
+// filterKey joins path segments back into a filter key
+func filterKey(path []string) string {
+	return strings.Join(path, "][")
+}
+
+// filterClauses builds the clauses for each of the filters provided, returning
+// the clauses for fields separately from the clauses for any groups
+func (qb QueryBuilder) filterClauses(filter map[string][]string, lo LogicalOperator) ([]bson.M, []bson.M, error) {
+	errs := ValidationErrors{}
+	fields := []bson.M{}
+	groups := map[string]map[int]map[string][]string{}
+
+	// iterate the fields in a consistent order so that the resulting filter
+	// (and any errors) are deterministic
+	for _, key := range sortedKeys(filter) {
+		values := filter[key]
+
+		// $text is only valid at the top level of a filter
+		if qb.isTextSearch(key) {
+			errs.add(&UnsupportedOperatorError{
+				Field:    key,
+				Value:    strings.Join(values, ","),
+				Operator: "$text",
+				Reason:   "text search is not supported within a filter group",
+			})
+			continue
+		}
+
+		// collect any grouped filters (i.e. or][0][status)
+		if op, idx, rest, ok := qb.groupPath(key); ok {
+			if groups[op] == nil {
+				groups[op] = map[int]map[string][]string{}
+			}
+
+			if groups[op][idx] == nil {
+				groups[op][idx] = map[string][]string{}
+			}
+
+			groups[op][idx][rest] = values
+			continue
+		}
+
+		f, err := qb.fieldFilter(key, values, lo)
+		if err != nil {
+			errs.add(err)
+			continue
+		}
+
+		if len(f) > 0 {
+			fields = append(fields, f)
+		}
+	}
+
+	// a negated group is the $nor of the group (De Morgan) rather than a group of
+	// negated filters, which would match documents that fail any one filter...
+	// the filters within the group match any of the values (as Not is none of the
+	// values)
+	mlo := lo
+	if lo == Not {
+		mlo = Or
+	}
+
+	// build each group from the filters for each index of the group
+	clauses := []bson.M{}
+	for _, op := range sortedKeys(groups) {
+		members := bson.A{}
+
+		idxs := make([]int, 0, len(groups[op]))
+		for idx := range groups[op] {
+			idxs = append(idxs, idx)
+		}
+
+		sort.Ints(idxs)
+
+		for _, idx := range idxs {
+			sf, sg, err := qb.filterClauses(groups[op][idx], mlo)
+			if err != nil {
+				errs.add(err)
+				continue
+			}
+
+			// the filters within a single index of a group are combined with $and
+			if m := qb.matchArrayElements(combineGroups(qb.matchSubDocuments(sf, And), sg, And)); len(m) > 0 {
+				members = append(members, m)
+			}
+		}
+
+		if len(members) == 0 {
+			continue
+		}
+
+		g := bson.M{groupOperators[op].String(): members}
+		if lo == Not {
+			g = bson.M{Nor.String(): bson.A{g}}
+		}
+
+		clauses = append(clauses, g)
+	}
+
+	return fields, clauses, errs.err()
+}
+
+// groupPath determines if the filter key is part of a group and returns the
+// group operator, the index within the group and the remaining filter key
+func (qb QueryBuilder) groupPath(key string) (string, int, string, bool) {
+	path := filterKeyPath(key)
+	if len(path) < 3 {
+		return "", 0, "", false
+	}
+
+	// a field defined in the schema takes precedence over a group operator
+	op := strings.ToLower(path[0])
+	if _, ok := groupOperators[op]; !ok {
+		return "", 0, "", false
+	}
+
+	if _, ok := qb.fieldTypes[path[0]]; ok {
+		return "", 0, "", false
+	}
+
+	idx, err := strconv.Atoi(path[1])
+	if err != nil || idx < 0 {
+		return "", 0, "", false
+	}
+
+	return op, idx, filterKey(path[2:]), true
+}
+
+// combineGroups combines field clauses using the provided logical operator and
+// then adds each group clause... when combining with $and, a group whose
+// operator is already present in the filter is nested within $and so that the
+// group is not merged with an unrelated clause
+func combineGroups(fields []bson.M, groups []bson.M, lo LogicalOperator) bson.M {
+	if lo != And {
+		return combineClauses(append(fields, groups...), lo)
+	}
+
+	filter := combineClauses(fields, lo)
+	for _, g := range groups {
+		for k := range g {
+			if _, ok := filter[k]; ok && k != And.String() {
+				g = bson.M{And.String(): bson.A{g}}
+			}
+		}
+
+		filter = combine(filter, g)
+	}
+
+	return filter
+}
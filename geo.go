@@ -0,0 +1,249 @@
+package querybuilder
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// geoOperators maps the geospatial operators that can be provided in brackets
+// following a GeoJSON field in the querystring (i.e. filter[location][near])
+var geoOperators = map[string]string{
+	"near":            "near",
+	"within][box":     "box",
+	"within][polygon": "polygon",
+}
+
+// isGeoJSON determines if the properties of an object in the schema describe a
+// GeoJSON object, which has both a type and coordinates
+func isGeoJSON(properties bson.M) bool {
+	_, t := properties["type"]
+	_, c := properties["coordinates"]
+
+	return t && c
+}
+
+// geoPath determines if the filter key includes a geospatial operator for a
+// GeoJSON field (i.e. location][near) and returns the field and the operator
+func (qb QueryBuilder) geoPath(key string) (string, string, bool) {
+	// a field defined in the schema takes precedence
+	if _, ok := qb.fieldTypes[key]; ok {
+		return "", "", false
+	}
+
+	path := filterKeyPath(key)
+	if len(path) < 2 || !qb.geoFields[path[0]] {
+		return "", "", false
+	}
+
+	oper, ok := geoOperators[strings.ToLower(filterKey(path[1:]))]
+	if !ok {
+		return "", "", false
+	}
+
+	return path[0], oper, true
+}
+
+// geoFilter builds the clause for a GeoJSON field using a geospatial operator:
+// * near: lng,lat[,maxMeters[,minMeters]] using $nearSphere
+// * box: minLng,minLat,maxLng,maxLat using $geoWithin with a Polygon
+// * polygon: lng,lat,lng,lat,lng,lat... using $geoWithin with a Polygon (the
+// ring is closed when the last point differs from the first)
+func (qb QueryBuilder) geoFilter(field string, oper string, values []string) (bson.M, error) {
+	nums, err := parseCoordinates(values)
+	if err != nil {
+		return nil, invalidValue(field, strings.Join(values, ","), "", err)
+	}
+
+	var clause bson.D
+	switch oper {
+	case "near":
+		clause, err = nearClause(nums)
+	case "box":
+		clause, err = boxClause(nums)
+	case "polygon":
+		clause, err = polygonClause(nums)
+	}
+
+	if err != nil {
+		return nil, invalidValue(field, strings.Join(values, ","), "", err)
+	}
+
+	return bson.M{field: clause}, nil
+}
+
+// validateNear reports each $nearSphere clause within the filter that MongoDB
+// does not support... $nearSphere is only supported at the top level of a find
+// filter (or within $and) and is not supported in an aggregation (i.e. the
+// $match stage of a Pipeline or CountDocuments)
+func validateNear(filter bson.M, aggregate bool) error {
+	errs := ValidationErrors{}
+
+	nearFields(filter, "", true, func(field string, top bool) {
+		switch {
+		case aggregate:
+			errs.add(&UnsupportedOperatorError{
+				Field:    field,
+				Operator: "$nearSphere",
+				Reason:   "near is not supported in an aggregation ($geoNear is required)",
+			})
+		case !top:
+			errs.add(&UnsupportedOperatorError{
+				Field:    field,
+				Operator: "$nearSphere",
+				Reason:   "near is not supported within $or, $nor or $not",
+			})
+		}
+	})
+
+	return errs.err()
+}
+
+// nearFields calls fn for each field with a $nearSphere clause within the value
+// along with whether the clause is at the top level (only nested within $and)
+func nearFields(v any, field string, top bool, fn func(field string, top bool)) {
+	key := func(k string, v any) {
+		switch {
+		case k == "$nearSphere":
+			fn(field, top)
+		case k == And.String():
+			nearFields(v, field, top, fn)
+		case strings.HasPrefix(k, "$"):
+			nearFields(v, field, false, fn)
+		default:
+			// the operator expression of a field within a field is not top level
+			nearFields(v, k, top && field == "", fn)
+		}
+	}
+
+	switch v := v.(type) {
+	case bson.M:
+		for _, k := range sortedKeys(v) {
+			key(k, v[k])
+		}
+	case bson.D:
+		for _, e := range v {
+			key(e.Key, e.Value)
+		}
+	case bson.A:
+		for _, e := range v {
+			nearFields(e, field, top, fn)
+		}
+	}
+}
+
+// nearClause builds $nearSphere for a point with an optional maximum and
+// minimum distance in meters
+func nearClause(nums []float64) (bson.D, error) {
+	if len(nums) < 2 || len(nums) > 4 {
+		return nil, fmt.Errorf("expected lng,lat with an optional maximum and minimum distance in meters")
+	}
+
+	if err := validatePoint(nums[0], nums[1]); err != nil {
+		return nil, err
+	}
+
+	near := bson.D{bson.E{Key: "$geometry", Value: bson.D{
+		bson.E{Key: "type", Value: "Point"},
+		bson.E{Key: "coordinates", Value: bson.A{nums[0], nums[1]}},
+	}}}
+
+	for i, oper := range []string{"$maxDistance", "$minDistance"} {
+		if len(nums) < i+3 {
+			break
+		}
+
+		if nums[i+2] < 0 {
+			return nil, fmt.Errorf("expected a distance in meters that is not negative")
+		}
+
+		near = append(near, bson.E{Key: oper, Value: nums[i+2]})
+	}
+
+	if len(nums) == 4 && nums[3] > nums[2] {
+		return nil, fmt.Errorf("expected the minimum distance to be less than the maximum distance")
+	}
+
+	return bson.D{bson.E{Key: "$nearSphere", Value: near}}, nil
+}
+
+// boxClause builds $geoWithin for a bounding box as a Polygon
+func boxClause(nums []float64) (bson.D, error) {
+	if len(nums) != 4 {
+		return nil, fmt.Errorf("expected minLng,minLat,maxLng,maxLat")
+	}
+
+	if nums[0] > nums[2] || nums[1] > nums[3] {
+		return nil, fmt.Errorf("expected the minimum lng and lat to be less than the maximum lng and lat")
+	}
+
+	return polygonClause([]float64{
+		nums[0], nums[1],
+		nums[2], nums[1],
+		nums[2], nums[3],
+		nums[0], nums[3],
+	})
+}
+
+// polygonClause builds $geoWithin for a Polygon with a single ring
+func polygonClause(nums []float64) (bson.D, error) {
+	if len(nums)%2 != 0 || len(nums) < 6 {
+		return nil, fmt.Errorf("expected at least 3 lng,lat points")
+	}
+
+	ring := bson.A{}
+	for i := 0; i < len(nums); i += 2 {
+		if err := validatePoint(nums[i], nums[i+1]); err != nil {
+			return nil, err
+		}
+
+		ring = append(ring, bson.A{nums[i], nums[i+1]})
+	}
+
+	// a GeoJSON ring must be closed
+	if nums[0] != nums[len(nums)-2] || nums[1] != nums[len(nums)-1] {
+		ring = append(ring, bson.A{nums[0], nums[1]})
+	}
+
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("expected at least 3 distinct lng,lat points")
+	}
+
+	return bson.D{bson.E{Key: "$geoWithin", Value: bson.D{
+		bson.E{Key: "$geometry", Value: bson.D{
+			bson.E{Key: "type", Value: "Polygon"},
+			bson.E{Key: "coordinates", Value: bson.A{ring}},
+		}},
+	}}}, nil
+}
+
+// parseCoordinates parses each of the values as a number
+func parseCoordinates(values []string) ([]float64, error) {
+	nums := make([]float64, 0, len(values))
+	for _, v := range values {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("expected a number for each coordinate and distance")
+		}
+
+		nums = append(nums, n)
+	}
+
+	return nums, nil
+}
+
+// validatePoint ensures the longitude and latitude are within range
+func validatePoint(lng float64, lat float64) error {
+	if lng < -180 || lng > 180 {
+		return fmt.Errorf("expected a longitude between -180 and 180")
+	}
+
+	if lat < -90 || lat > 90 {
+		return fmt.Errorf("expected a latitude between -90 and 90")
+	}
+
+	return nil
+}
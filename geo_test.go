@@ -0,0 +1,254 @@
+package querybuilder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	queryoptions "go.jtlabs.io/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var geoSchema = bson.M{
+	"$jsonSchema": bson.M{
+		"bsonType": "object",
+		"properties": bson.M{
+			"name": bson.M{
+				"bsonType": "string",
+			},
+			"location": bson.M{
+				"bsonType": "object",
+				"properties": bson.M{
+					"type": bson.M{
+						"bsonType": "string",
+						"enum":     bson.A{"Point"},
+					},
+					"coordinates": bson.M{
+						"bsonType": "array",
+						"items": bson.M{
+							"bsonType": "double",
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestQueryBuilder_Filter_Geo(t *testing.T) {
+	point := func(lng, lat float64) bson.D {
+		return bson.D{
+			bson.E{Key: "type", Value: "Point"},
+			bson.E{Key: "coordinates", Value: bson.A{lng, lat}},
+		}
+	}
+
+	polygon := func(ring ...bson.A) bson.D {
+		r := bson.A{}
+		for _, p := range ring {
+			r = append(r, p)
+		}
+
+		return bson.D{bson.E{Key: "$geoWithin", Value: bson.D{
+			bson.E{Key: "$geometry", Value: bson.D{
+				bson.E{Key: "type", Value: "Polygon"},
+				bson.E{Key: "coordinates", Value: bson.A{r}},
+			}},
+		}}}
+	}
+
+	tests := []struct {
+		name    string
+		filter  map[string][]string
+		opts    *queryBuilderOptions
+		lo      []LogicalOperator
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			name: "should use $nearSphere for a radius search",
+			filter: map[string][]string{
+				"location][near": {"-122.4", "37.8", "500"},
+			},
+			want: bson.M{
+				"location": bson.D{bson.E{Key: "$nearSphere", Value: bson.D{
+					bson.E{Key: "$geometry", Value: point(-122.4, 37.8)},
+					bson.E{Key: "$maxDistance", Value: float64(500)},
+				}}},
+			},
+		},
+		{
+			name: "should include a minimum distance",
+			filter: map[string][]string{
+				"location][near": {"10", "20", "500", "100"},
+			},
+			want: bson.M{
+				"location": bson.D{bson.E{Key: "$nearSphere", Value: bson.D{
+					bson.E{Key: "$geometry", Value: point(10, 20)},
+					bson.E{Key: "$maxDistance", Value: float64(500)},
+					bson.E{Key: "$minDistance", Value: float64(100)},
+				}}},
+			},
+		},
+		{
+			name: "should use $geoWithin with a polygon for a bounding box",
+			filter: map[string][]string{
+				"location][within][box": {"-10", "-5", "10", "5"},
+				"name":                  {"cafe"},
+			},
+			want: bson.M{
+				"location": polygon(
+					bson.A{float64(-10), float64(-5)},
+					bson.A{float64(10), float64(-5)},
+					bson.A{float64(10), float64(5)},
+					bson.A{float64(-10), float64(5)},
+					bson.A{float64(-10), float64(-5)},
+				),
+				"name": "cafe",
+			},
+		},
+		{
+			name: "should close the ring of a polygon",
+			filter: map[string][]string{
+				"location][within][polygon": {"0", "0", "4", "0", "4", "4"},
+			},
+			want: bson.M{
+				"location": polygon(
+					bson.A{float64(0), float64(0)},
+					bson.A{float64(4), float64(0)},
+					bson.A{float64(4), float64(4)},
+					bson.A{float64(0), float64(0)},
+				),
+			},
+		},
+		{
+			name: "should check for the existence of a GeoJSON field",
+			filter: map[string][]string{
+				"location][exists": {"true"},
+			},
+			want: bson.M{
+				"location": bson.D{bson.E{Key: "$exists", Value: true}},
+			},
+		},
+		{
+			name: "should error for coordinates that are out of range",
+			filter: map[string][]string{
+				"location][near": {"190", "10"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error for a negative distance",
+			filter: map[string][]string{
+				"location][near": {"10", "10", "-1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error for a box with the bounds reversed",
+			filter: map[string][]string{
+				"location][within][box": {"10", "5", "-10", "-5"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error for a polygon with too few points",
+			filter: map[string][]string{
+				"location][within][polygon": {"0", "0", "4", "0", "0", "0"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error for a geospatial operator on a field that is not GeoJSON",
+			filter: map[string][]string{
+				"name][near": {"10", "10"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should allow a radius search within an and group",
+			filter: map[string][]string{
+				"and][0][location][near": {"10", "10"},
+			},
+			want: bson.M{
+				"$and": bson.A{
+					bson.M{"location": bson.D{bson.E{Key: "$nearSphere", Value: bson.D{
+						bson.E{Key: "$geometry", Value: point(10, 10)},
+					}}}},
+				},
+			},
+		},
+		{
+			name: "should error for a radius search combined with $or",
+			filter: map[string][]string{
+				"location][near": {"10", "10"},
+				"name":           {"park"},
+			},
+			opts:    QueryBuilderOptions().SetFieldOperator(Or),
+			wantErr: true,
+		},
+		{
+			name: "should error for a radius search within an or group",
+			filter: map[string][]string{
+				"or][0][location][near": {"10", "10"},
+				"or][1][name":           {"park"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error for a negated radius search",
+			filter: map[string][]string{
+				"location][near": {"10", "10"},
+			},
+			lo:      []LogicalOperator{Not},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder("test", geoSchema, true).WithOptions(tt.opts)
+
+			got, err := qb.Filter(queryoptions.Options{Filter: tt.filter}, tt.lo...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Geo_Aggregation(t *testing.T) {
+	qb := NewQueryBuilder("test", geoSchema, true)
+	qo := queryoptions.Options{
+		Filter: map[string][]string{
+			"location][near": {"10", "10"},
+		},
+	}
+
+	// a radius search is supported by Find
+	if _, err := qb.Filter(qo); err != nil {
+		t.Errorf("QueryBuilder.Filter() error = %v", err)
+	}
+
+	var uoe *UnsupportedOperatorError
+	if _, err := qb.Pipeline(qo); !errors.As(err, &uoe) || uoe.Field != "location" {
+		t.Errorf("QueryBuilder.Pipeline() error = %v, want *UnsupportedOperatorError", err)
+	}
+
+	if _, err := qb.CountOptions(qo); !errors.As(err, &uoe) || uoe.Collection != "test" {
+		t.Errorf("QueryBuilder.CountOptions() error = %v, want *UnsupportedOperatorError", err)
+	}
+
+	// other geospatial operators are supported in an aggregation
+	qo.Filter = map[string][]string{
+		"location][within][box": {"-10", "-5", "10", "5"},
+	}
+
+	if _, err := qb.Pipeline(qo); err != nil {
+		t.Errorf("QueryBuilder.Pipeline() error = %v", err)
+	}
+}
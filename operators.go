@@ -0,0 +1,335 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// namedOperators maps the operators that can be provided in brackets following
+// a field name in the querystring (i.e. filter[price][gte]=10) to the Mongo
+// query operator... contains, startsWith and endsWith are built as a regex
+var namedOperators = map[string]string{
+	"all":        "$all",
+	"contains":   "contains",
+	"endswith":   "endsWith",
+	"eq":         "$eq",
+	"exact":      "exact",
+	"exists":     "$exists",
+	"gt":         "$gt",
+	"gte":        "$gte",
+	"in":         "$in",
+	"lt":         "$lt",
+	"lte":        "$lte",
+	"ne":         "$ne",
+	"nin":        "$nin",
+	"size":       "$size",
+	"startswith": "startsWith",
+	"type":       "$type",
+}
+
+// bsonTypes are the aliases of the BSON types that can be provided to the type
+// named operator (i.e. filter[value][type]=string)... number matches any of the
+// numeric types
+var bsonTypes = map[string]bool{
+	"array":               true,
+	"binData":             true,
+	"bool":                true,
+	"date":                true,
+	"dbPointer":           true,
+	"decimal":             true,
+	"double":              true,
+	"int":                 true,
+	"javascript":          true,
+	"javascriptWithScope": true,
+	"long":                true,
+	"maxKey":              true,
+	"minKey":              true,
+	"null":                true,
+	"number":              true,
+	"object":              true,
+	"objectId":            true,
+	"regex":               true,
+	"string":              true,
+	"symbol":              true,
+	"timestamp":           true,
+	"undefined":           true,
+}
+
+// operatorPath determines if the filter key includes a named operator (i.e.
+// price][gte) and returns the field and the operator
+func (qb QueryBuilder) operatorPath(key string) (string, string, bool) {
+	// a field defined in the schema takes precedence
+	if _, ok := qb.fieldTypes[key]; ok {
+		return "", "", false
+	}
+
+	path := filterKeyPath(key)
+	if len(path) != 2 {
+		return "", "", false
+	}
+
+	oper, ok := namedOperators[strings.ToLower(path[1])]
+	if !ok {
+		return "", "", false
+	}
+
+	return path[0], oper, true
+}
+
+// operatorFilter builds the clause for a field using a named operator... values
+// are parsed as the bsonType of the field without any prefix or suffix
+// detection so that values such as negative numbers are not ambiguous
+func (qb QueryBuilder) operatorFilter(field string, oper string, values []string) (bson.M, error) {
+	bsonType, err := qb.fieldType(field, values)
+	if err != nil || bsonType == "" || len(values) == 0 {
+		return nil, err
+	}
+
+	// a field that allows several types uses the first type that is able to
+	// parse the values
+	for i, bt := range qb.allowedTypes(field, bsonType) {
+		f, terr := qb.typedOperatorFilter(field, oper, values, bt)
+		if terr == nil {
+			return f, nil
+		}
+
+		if i == 0 {
+			err = terr
+		}
+	}
+
+	return nil, err
+}
+
+// typedOperatorFilter builds the clause for a field using a named operator with
+// the values parsed as the provided bsonType
+func (qb QueryBuilder) typedOperatorFilter(field string, oper string, values []string, bsonType string) (bson.M, error) {
+
+	switch oper {
+	case "$exists":
+		if len(values) > 1 {
+			return nil, invalidValue(field, strings.Join(values, ","), "", fmt.Errorf("expected a single true or false value"))
+		}
+
+		exists, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return nil, invalidValue(field, values[0], "", err)
+		}
+
+		return bson.M{field: bson.D{bson.E{
+			Key:   oper,
+			Value: exists,
+		}}}, nil
+	case "$type":
+		return typeFilter(field, oper, values)
+	case "contains", "endsWith", "startsWith":
+		return patternFilter(field, oper, values, bsonType, qb.patterns(field))
+	case "$all", "$size", "exact":
+		return qb.arrayOperatorFilter(field, oper, values, bsonType)
+	}
+
+	// object fields are only able to be filtered by the existence of the field
+	if bsonType == "object" {
+		return nil, &UnsupportedOperatorError{
+			Field:    field,
+			Value:    strings.Join(values, ","),
+			Operator: oper,
+			Reason:   "object fields only support the exists operator",
+		}
+	}
+
+	// only equality comparisons are meaningful for bool
+	switch oper {
+	case "$gt", "$gte", "$lt", "$lte":
+		if bsonType == "binData" || bsonType == "bool" {
+			return nil, &UnsupportedOperatorError{
+				Field:    field,
+				Value:    strings.Join(values, ","),
+				Operator: oper,
+				Reason:   fmt.Sprintf("%s fields only support equality, $in and $nin comparisons", bsonType),
+			}
+		}
+
+		if len(values) > 1 {
+			return nil, invalidValue(field, strings.Join(values, ","), bsonType, fmt.Errorf("expected a single value for the %s operator", oper))
+		}
+	}
+
+	// dates are compared using date expressions so that partial and relative
+	// dates (i.e. 2024-05 or now-7d) are supported
+	if bsonType == "date" || bsonType == "timestamp" {
+		return qb.dateOperatorFilter(field, oper, values, bsonType)
+	}
+
+	a := bson.A{}
+	for _, v := range values {
+		tv, err := qb.typedValue(field, v, bsonType)
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, tv)
+	}
+
+	// multiple values for equality are compared with $in and $nin
+	if len(a) > 1 {
+		switch oper {
+		case "$eq":
+			oper = "$in"
+		case "$ne":
+			oper = "$nin"
+		}
+	}
+
+	switch oper {
+	case "$eq":
+		return bson.M{field: a[0]}, nil
+	case "$in", "$nin":
+		return bson.M{field: bson.D{bson.E{
+			Key:   oper,
+			Value: a,
+		}}}, nil
+	}
+
+	return bson.M{field: bson.D{bson.E{
+		Key:   oper,
+		Value: a[0],
+	}}}, nil
+}
+
+// dateOperatorFilter builds the clause for a date or timestamp field using a
+// named operator by applying the equivalent prefix to each value (date and
+// timestamp values never begin with a prefix, so the values are not ambiguous)
+func (qb QueryBuilder) dateOperatorFilter(field string, oper string, values []string, bsonType string) (bson.M, error) {
+	prefix := map[string]string{
+		"$gt":  ">",
+		"$gte": ">=",
+		"$lt":  "<",
+		"$lte": "<=",
+		"$ne":  "!=",
+		"$nin": "!=",
+	}[oper]
+
+	// any of the values for $eq and $in may match
+	lo := And
+	if oper == "$eq" || oper == "$in" {
+		lo = Or
+	}
+
+	pv := make([]string, 0, len(values))
+	for _, v := range values {
+		pv = append(pv, prefix+v)
+	}
+
+	if bsonType == "timestamp" {
+		return detectTimestampComparisonOperator(field, pv, lo, qb.opts.exclusive(), qb.dateParser())
+	}
+
+	return detectDateComparisonOperator(field, pv, bsonType, lo, qb.opts.exclusive(), qb.dateParser().parse)
+}
+
+// typedValue parses a single value as the bsonType of the field using the
+// options of the builder (i.e. the subtype of a binData field)
+func (qb QueryBuilder) typedValue(field string, value string, bsonType string) (any, error) {
+	if bsonType != "binData" || value == "null" {
+		return parseTypedValue(field, value, bsonType)
+	}
+
+	b, err := parseBinary(value, qb.opts.subtype(field))
+	if err != nil {
+		return nil, invalidValue(field, value, bsonType, err)
+	}
+
+	return b, nil
+}
+
+// parseTypedValue parses a single value as the bsonType of the field... the
+// keyword null is supported for every bsonType
+func parseTypedValue(field string, value string, bsonType string) (any, error) {
+	if value == "null" {
+		return nil, nil
+	}
+
+	switch bsonType {
+	case "bool":
+		bv, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, invalidValue(field, value, bsonType, err)
+		}
+
+		return bv, nil
+	case "decimal", "double", "int", "long", "number":
+		nv, err := parseNumericValue(value, bsonType)
+		if err != nil {
+			return nil, invalidValue(field, value, bsonType, err)
+		}
+
+		return nv, nil
+	case "objectId":
+		oid, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, invalidValue(field, value, bsonType, err)
+		}
+
+		return oid, nil
+	}
+
+	return value, nil
+}
+
+// typeFilter builds the $type clause for any field... multiple values match any
+// of the BSON types
+func typeFilter(field string, oper string, values []string) (bson.M, error) {
+	a := bson.A{}
+	for _, v := range values {
+		if !bsonTypes[v] {
+			return nil, invalidValue(field, v, "", fmt.Errorf("expected a BSON type alias (i.e. string, int, date or null)"))
+		}
+
+		a = append(a, v)
+	}
+
+	if len(a) == 1 {
+		return bson.M{field: bson.D{bson.E{
+			Key:   oper,
+			Value: a[0],
+		}}}, nil
+	}
+
+	return bson.M{field: bson.D{bson.E{
+		Key:   oper,
+		Value: a,
+	}}}, nil
+}
+
+// patternFilter builds a regex for the contains, startsWith and endsWith
+// operators... the value is escaped so that it is matched as is
+func patternFilter(field string, oper string, values []string, bsonType string, pb patternBuilder) (bson.M, error) {
+	if bsonType != "array" && bsonType != "string" {
+		return nil, &UnsupportedOperatorError{
+			Field:    field,
+			Value:    strings.Join(values, ","),
+			Operator: oper,
+			Reason:   fmt.Sprintf("%s fields do not support pattern matching", bsonType),
+		}
+	}
+
+	a := bson.A{}
+	for _, v := range values {
+		a = append(a, pb.match(v, oper))
+	}
+
+	if len(a) == 1 {
+		return bson.M{field: a[0]}, nil
+	}
+
+	// any of the patterns may match
+	return bson.M{field: bson.D{bson.E{
+		Key:   "$in",
+		Value: a,
+	}}}, nil
+}
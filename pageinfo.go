@@ -0,0 +1,79 @@
+package querybuilder
+
+import (
+	queryoptions "go.jtlabs.io/query"
+)
+
+// PageInfo contains the pagination details for a list of documents based on
+// the pagination requested in query options and the total count of documents
+// that match the filter. Both the limit/offset (or limit/skip) and the
+// page/size pagination styles are supported. Page numbers are zero based,
+// matching the page/size pagination style (i.e. page[page]=1 is the second page).
+type PageInfo struct {
+	Page       int64 `json:"page"`
+	Size       int64 `json:"size"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"totalPages"`
+	HasNext    bool  `json:"hasNext"`
+	HasPrev    bool  `json:"hasPrev"`
+	Offset     int64 `json:"offset"`
+	NextOffset int64 `json:"nextOffset"`
+	PrevOffset int64 `json:"prevOffset"`
+}
+
+// NewPageInfo calculates the pagination details for the query options and the
+// total count of documents that match the filter (i.e. the result of
+// CountDocuments when used with CountOptions). When no pagination is requested,
+// every document is considered to be part of a single page.
+//
+//	func example() {
+//		// ?page[size]=10&page[page]=2 with 45 total documents results in:
+//		// PageInfo{
+//		//   Page:       2,
+//		//   Size:       10,
+//		//   Total:      45,
+//		//   TotalPages: 5,
+//		//   HasNext:    true,
+//		//   HasPrev:    true,
+//		//   Offset:     20,
+//		//   NextOffset: 30,
+//		//   PrevOffset: 10,
+//		// }
+//		pi := NewPageInfo(opt, total)
+//	}
+func NewPageInfo(qo queryoptions.Options, total int64) PageInfo {
+	pi := PageInfo{
+		Total: total,
+	}
+
+	limit, skip := paginationBounds(qo.Page)
+
+	if skip != nil && *skip > 0 {
+		pi.Offset = *skip
+	}
+
+	// without a limit, the remaining documents are a single page
+	pi.Size = total - pi.Offset
+	if limit != nil && *limit > 0 {
+		pi.Size = *limit
+	}
+
+	if pi.Size <= 0 {
+		return pi
+	}
+
+	pi.Page = pi.Offset / pi.Size
+	pi.TotalPages = (total + pi.Size - 1) / pi.Size
+	pi.HasNext = pi.Offset+pi.Size < total
+	pi.HasPrev = pi.Offset > 0
+
+	if pi.HasNext {
+		pi.NextOffset = pi.Offset + pi.Size
+	}
+
+	if pi.HasPrev {
+		pi.PrevOffset = max(pi.Offset-pi.Size, 0)
+	}
+
+	return pi
+}
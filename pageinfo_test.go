@@ -0,0 +1,118 @@
+package querybuilder
+
+import (
+	"reflect"
+	"testing"
+
+	queryoptions "go.jtlabs.io/query"
+)
+
+func TestNewPageInfo(t *testing.T) {
+	type args struct {
+		page  map[string]int
+		total int64
+	}
+	tests := []struct {
+		name string
+		args args
+		want PageInfo
+	}{
+		{
+			name: "should treat every document as a single page without pagination",
+			args: args{
+				page:  map[string]int{},
+				total: 45,
+			},
+			want: PageInfo{
+				Size:       45,
+				Total:      45,
+				TotalPages: 1,
+			},
+		},
+		{
+			name: "should not calculate pages when there are no documents",
+			args: args{
+				page:  map[string]int{},
+				total: 0,
+			},
+			want: PageInfo{},
+		},
+		{
+			name: "should calculate page details with page and size",
+			args: args{
+				page:  map[string]int{"page": 2, "size": 10},
+				total: 45,
+			},
+			want: PageInfo{
+				Page:       2,
+				Size:       10,
+				Total:      45,
+				TotalPages: 5,
+				HasNext:    true,
+				HasPrev:    true,
+				Offset:     20,
+				NextOffset: 30,
+				PrevOffset: 10,
+			},
+		},
+		{
+			name: "should calculate page details with limit and offset",
+			args: args{
+				page:  map[string]int{"limit": 10, "offset": 40},
+				total: 45,
+			},
+			want: PageInfo{
+				Page:       4,
+				Size:       10,
+				Total:      45,
+				TotalPages: 5,
+				HasNext:    false,
+				HasPrev:    true,
+				Offset:     40,
+				PrevOffset: 30,
+			},
+		},
+		{
+			name: "should calculate page details with limit and skip on the first page",
+			args: args{
+				page:  map[string]int{"limit": 10, "skip": 0},
+				total: 45,
+			},
+			want: PageInfo{
+				Page:       0,
+				Size:       10,
+				Total:      45,
+				TotalPages: 5,
+				HasNext:    true,
+				HasPrev:    false,
+				NextOffset: 10,
+			},
+		},
+		{
+			name: "should not allow a previous offset below zero",
+			args: args{
+				page:  map[string]int{"limit": 10, "offset": 5},
+				total: 45,
+			},
+			want: PageInfo{
+				Page:       0,
+				Size:       10,
+				Total:      45,
+				TotalPages: 5,
+				HasNext:    true,
+				HasPrev:    true,
+				Offset:     5,
+				NextOffset: 15,
+				PrevOffset: 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewPageInfo(queryoptions.Options{Page: tt.args.page}, tt.args.total)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewPageInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package querybuilder
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var reRawRegex = regexp.MustCompile(`^/(.+)/([imsx]*)$`)
+
+// patternBuilder builds the regex patterns for string filters (i.e. *term*)...
+// values are escaped so that they are matched as is unless raw regex values
+// (i.e. /^ab.*$/i) have been enabled with a maximum length
+type patternBuilder struct {
+	options   string
+	rawLength int
+}
+
+// match builds the regex for a value that contains, starts with or ends with
+// the value... oper is one of contains, startsWith or endsWith
+func (pb patternBuilder) match(value string, oper string) primitive.Regex {
+	pattern := regexp.QuoteMeta(value)
+
+	switch oper {
+	case "endsWith":
+		pattern = fmt.Sprintf("%s$", pattern)
+	case "startsWith":
+		pattern = fmt.Sprintf("^%s", pattern)
+	}
+
+	return primitive.Regex{
+		Pattern: pattern,
+		Options: pb.options,
+	}
+}
+
+// raw determines if the value is a raw regex (i.e. /^ab.*$/i) and returns the
+// regex when raw regex values are enabled... the pattern must be valid RE2
+// syntax (which excludes backreferences and lookarounds) without nested
+// quantifiers and the options are limited to i, m, s and x. MongoDB evaluates
+// the pattern with PCRE, which backtracks, so the length of the pattern and the
+// rejection of nested quantifiers (i.e. (a+)+$) limit the cost of evaluation.
+func (pb patternBuilder) raw(value string) (primitive.Regex, bool, error) {
+	if pb.rawLength <= 0 {
+		return primitive.Regex{}, false, nil
+	}
+
+	m := reRawRegex.FindStringSubmatch(value)
+	if m == nil {
+		return primitive.Regex{}, false, nil
+	}
+
+	if len(m[1]) > pb.rawLength {
+		return primitive.Regex{}, true, fmt.Errorf("expected a regex pattern of at most %d characters", pb.rawLength)
+	}
+
+	re, err := syntax.Parse(m[1], syntax.Perl)
+	if err != nil {
+		return primitive.Regex{}, true, fmt.Errorf("expected a valid regex pattern: %w", err)
+	}
+
+	if nestedQuantifier(re, false) {
+		return primitive.Regex{}, true, fmt.Errorf("expected a regex pattern without nested quantifiers (i.e. (a+)+)")
+	}
+
+	return primitive.Regex{
+		Pattern: m[1],
+		Options: m[2],
+	}, true, nil
+}
+
+// nestedQuantifier determines if a quantifier that repeats (i.e. *, + or {2,})
+// applies to an expression that also contains a quantifier that repeats, which
+// is evaluated with catastrophic backtracking by PCRE
+func nestedQuantifier(re *syntax.Regexp, repeated bool) bool {
+	repeats := re.Op == syntax.OpStar ||
+		re.Op == syntax.OpPlus ||
+		(re.Op == syntax.OpRepeat && (re.Max == -1 || re.Max > 1))
+
+	if repeats && repeated {
+		return true
+	}
+
+	for _, sub := range re.Sub {
+		if nestedQuantifier(sub, repeated || repeats) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package querybuilder
+
+import (
+	queryoptions "go.jtlabs.io/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Pipeline builds an aggregation pipeline suitable for use with the Mongo driver
+// Aggregate methods from the provided query options. The filter is added as a
+// $match stage, followed by $sort, $skip, $limit and $project stages. The same
+// schema typing and validation that is used by Filter and FindOptions applies
+// and every problem found is reported at once in a ValidationErrors collection.
+//
+// When SetPipelineFacet is enabled, the pagination and projection stages are
+// wrapped in a $facet stage so that a single page of data and the total count
+// of matching documents are returned in one round trip. The result is a single
+// document that looks like:
+//
+//	{
+//		"data": [ ...documents for the page... ],
+//		"total": [ { "count": 100 } ]
+//	}
+func (qb QueryBuilder) Pipeline(qo queryoptions.Options, o ...LogicalOperator) (mongo.Pipeline, error) {
+	errs := ValidationErrors{}
+	pl := mongo.Pipeline{}
+
+	filter, err := qb.Filter(qo, o...)
+	errs.add(err)
+
+	// the $match stage does not support $nearSphere
+	errs.add(withCollection(validateNear(filter, true), qb.collection))
+
+	fo, err := qb.FindOptions(qo)
+	errs.add(err)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// add the $match stage
+	if len(filter) > 0 {
+		pl = append(pl, bson.D{bson.E{Key: "$match", Value: filter}})
+	}
+
+	// add the $sort stage
+	if sort, ok := fo.Sort.(bson.D); ok && len(sort) > 0 {
+		pl = append(pl, bson.D{bson.E{Key: "$sort", Value: sort}})
+	}
+
+	// determine the $skip, $limit and $project stages
+	page := []bson.D{}
+
+	if fo.Skip != nil && *fo.Skip > 0 {
+		page = append(page, bson.D{bson.E{Key: "$skip", Value: *fo.Skip}})
+	}
+
+	if fo.Limit != nil && *fo.Limit > 0 {
+		page = append(page, bson.D{bson.E{Key: "$limit", Value: *fo.Limit}})
+	}
+
+	// the projection includes the relevance score when sorting by a text search
+	p := bson.D{}
+	switch prj := fo.Projection.(type) {
+	case map[string]int:
+		for _, field := range sortedKeys(prj) {
+			p = append(p, bson.E{Key: field, Value: prj[field]})
+		}
+	case bson.M:
+		for _, field := range sortedKeys(prj) {
+			p = append(p, bson.E{Key: field, Value: prj[field]})
+		}
+	}
+
+	if len(p) > 0 {
+		page = append(page, bson.D{bson.E{Key: "$project", Value: p}})
+	}
+
+	// wrap the page in a $facet that includes the total count
+	if qb.opts.facet() {
+		data := bson.A{}
+		for _, stage := range page {
+			data = append(data, stage)
+		}
+
+		// a $facet sub-pipeline can not be empty
+		if len(data) == 0 {
+			data = append(data, bson.D{bson.E{Key: "$match", Value: bson.M{}}})
+		}
+
+		pl = append(pl, bson.D{bson.E{
+			Key: "$facet",
+			Value: bson.D{
+				bson.E{Key: "data", Value: data},
+				bson.E{Key: "total", Value: bson.A{
+					bson.D{bson.E{Key: "$count", Value: "count"}},
+				}},
+			},
+		}})
+
+		return pl, nil
+	}
+
+	return append(pl, page...), nil
+}
@@ -0,0 +1,168 @@
+package querybuilder
+
+import (
+	"reflect"
+	"testing"
+
+	queryoptions "go.jtlabs.io/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestQueryBuilder_Pipeline(t *testing.T) {
+	type fields struct {
+		collection       string
+		fieldTypes       map[string]string
+		opts             *queryBuilderOptions
+		strictValidation bool
+	}
+	type args struct {
+		qs string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    mongo.Pipeline
+		wantErr bool
+	}{
+		{
+			name: "should return an empty pipeline with no query args",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{},
+			},
+			args: args{
+				qs: "",
+			},
+			want:    mongo.Pipeline{},
+			wantErr: false,
+		},
+		{
+			name: "should build $match, $sort, $skip, $limit and $project stages",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"name": "string",
+					"age":  "int",
+				},
+			},
+			args: args{
+				qs: "filter[age]=%3E21&sort=-age,name&page[limit]=10&page[offset]=20&fields=name,age",
+			},
+			want: mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{
+					"age": bson.D{{Key: "$gt", Value: int32(21)}},
+				}}},
+				{{Key: "$sort", Value: bson.D{
+					{Key: "age", Value: -1},
+					{Key: "name", Value: 1},
+				}}},
+				{{Key: "$skip", Value: int64(20)}},
+				{{Key: "$limit", Value: int64(10)}},
+				{{Key: "$project", Value: bson.D{
+					{Key: "age", Value: 1},
+					{Key: "name", Value: 1},
+				}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should wrap the page in a $facet with the total count",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"name": "string",
+				},
+				opts: QueryBuilderOptions().SetPipelineFacet(true),
+			},
+			args: args{
+				qs: "filter[name]=test&sort=name&page[size]=10&page[page]=2",
+			},
+			want: mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"name": "test"}}},
+				{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}},
+				{{Key: "$facet", Value: bson.D{
+					{Key: "data", Value: bson.A{
+						bson.D{{Key: "$skip", Value: int64(20)}},
+						bson.D{{Key: "$limit", Value: int64(10)}},
+					}},
+					{Key: "total", Value: bson.A{
+						bson.D{{Key: "$count", Value: "count"}},
+					}},
+				}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should report filter and option errors together",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{"age": "int"},
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[age]=abc&sort=nofield",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should sort and project the text score for a text search",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"name": "string",
+				},
+			},
+			args: args{
+				qs: "filter[$search]=coffee&sort=score",
+			},
+			want: mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{
+					"$text": bson.D{{Key: "$search", Value: "coffee"}},
+				}}},
+				{{Key: "$sort", Value: bson.D{
+					{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}},
+				}}},
+				{{Key: "$project", Value: bson.D{
+					{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}},
+				}}},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := QueryBuilder{
+				collection:       tt.fields.collection,
+				fieldTypes:       tt.fields.fieldTypes,
+				opts:             tt.fields.opts,
+				strictValidation: tt.fields.strictValidation,
+			}
+
+			qo, err := queryoptions.FromQuerystring(tt.args.qs)
+			if err != nil {
+				t.Errorf("options.FromQuerystring() error = %v", err)
+				return
+			}
+
+			got, err := qb.Pipeline(qo)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.Pipeline() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				if ve, ok := err.(ValidationErrors); !ok || len(ve) != 2 {
+					t.Errorf("QueryBuilder.Pipeline() error = %v, want 2 ValidationErrors", err)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryBuilder.Pipeline() = \n%v\n, want \n%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,31 +1,44 @@
 package querybuilder
 
 import (
-	"fmt"
-	"strconv"
+	"errors"
+	"sort"
+	"strings"
 
 	queryoptions "go.jtlabs.io/query"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// errUnsupportedType is returned by typedFilter for a bsonType that can not be
+// filtered so that the next type of a field that allows several types is tried
+var errUnsupportedType = errors.New("filters are not supported for the bsonType")
+
 // QueryBuilder is a type that makes working with Mongo driver Find methods easier
 // when used in combination with a QueryOptions struct that specifies filters,
 // pagination details, sorting instructions and field projection details.
 type QueryBuilder struct {
+	arrayFields      map[string]bool
 	collection       string
 	fieldTypes       map[string]string
+	geoFields        map[string]bool
+	opts             *queryBuilderOptions
 	strictValidation bool
+	unionTypes       map[string][]string
 }
 
 // NewQueryBuilder returns a new instance of a QueryBuilder object for constructing
 // filters and options suitable for use with Mongo driver Find methods
 func NewQueryBuilder(collection string, schema any, strictValidation ...bool) *QueryBuilder {
+	sf := parseSchemaFields(schema)
 	qb := QueryBuilder{
+		arrayFields:      sf.arrays,
 		collection:       collection,
-		fieldTypes:       parseSchema(schema),
+		fieldTypes:       sf.types,
+		geoFields:        sf.geo,
+		opts:             QueryBuilderOptions(),
 		strictValidation: false,
+		unionTypes:       sf.unions,
 	}
 
 	// override strict validation if provided
@@ -36,28 +49,120 @@ func NewQueryBuilder(collection string, schema any, strictValidation ...bool) *Q
 	return &qb
 }
 
+// NewQueryBuilderE returns a new instance of a QueryBuilder in the same manner as
+// NewQueryBuilder after validating the schema. Every problem found within the
+// schema (i.e. JSON that can not be parsed, a bsonType that is not known or a
+// $ref that can not be resolved) is reported at once in a ValidationErrors
+// collection of *SchemaError values that include the JSON path of the problem.
+//
+//	qb, err := NewQueryBuilderE("things", schema, true)
+//	if err != nil {
+//		// invalid schema for collection things at $.properties.name.bsonType: ...
+//		log.Fatal(err)
+//	}
+func NewQueryBuilderE(collection string, schema any, strictValidation ...bool) (*QueryBuilder, error) {
+	if err := withCollection(validateSchema(schema), collection); err != nil {
+		return nil, err
+	}
+
+	return NewQueryBuilder(collection, schema, strictValidation...), nil
+}
+
+// WithOptions returns a copy of the QueryBuilder with the provided options merged
+// over any options already set on the QueryBuilder. Because a copy is returned,
+// this can be used once when the QueryBuilder is created or to override options
+// for a single call without affecting the original QueryBuilder.
+//
+//	// builder level options
+//	qb := NewQueryBuilder("things", schema).WithOptions(
+//		QueryBuilderOptions().SetSortTiebreaker("_id"))
+//
+//	// per call override
+//	fo, err := qb.WithOptions(QueryBuilderOptions().SetSortTiebreaker("thingID")).FindOptions(opt)
+func (qb QueryBuilder) WithOptions(opts ...*queryBuilderOptions) *QueryBuilder {
+	qb.opts = mergeQueryBuilderOptions(append([]*queryBuilderOptions{qb.opts}, opts...)...)
+	return &qb
+}
+
 // Filter builds a suitable bson document to send to any of the find methods
 // exposed by the Mongo driver. This method can validate the provided query
 // options against the schema that was used to build the QueryBuilder instance
-// when the QueryBuilder has strict validation enabled.
+// when the QueryBuilder has strict validation enabled. An error is also returned
+// when a value can not be parsed for the bsonType of the field (i.e. an
+// objectId value that is not a valid hex string). Every problem found is
+// reported at once in a ValidationErrors collection containing
+// *UnknownFieldError, *InvalidValueError and *UnsupportedOperatorError values.
+//
+// The optional LogicalOperator determines how multiple values provided for a
+// single field are combined. When Not is provided, the operator expression for
+// each field is negated (i.e. $in becomes $nin, a value becomes $ne and other
+// operators are wrapped in $not) so that documents matching none of the
+// values are returned. By default, the clauses for different fields are
+// combined with $and... use SetFieldOperator to combine them with $or or $nor
+// instead.
+//
+// Filters can be grouped using indexed and, or and nor groups which are
+// compiled into nested $and, $or and $nor clauses. The filters within a
+// single index of a group are combined with $and and groups can be nested:
+//
+//	// (status=open AND priority>3) OR assignee=me
+//	?filter[or][0][status]=open&filter[or][0][priority]=>3&filter[or][1][assignee]=me
+//
+// Operators can also be named in brackets following the field, which avoids
+// the ambiguity of the prefix operators with negative numbers. The values are
+// parsed as the bsonType of the field without any prefix or suffix detection:
+//
+//	// price >= 10 AND price < 20 AND name contains "abc"
+//	?filter[price][gte]=10&filter[price][lt]=20&filter[name][contains]=abc
+//
+// The named operators are eq, ne, gt, gte, lt, lte, in, nin, exists, type,
+// contains, startsWith, endsWith and, for array fields, all, exact and size.
+// The exists and type operators are supported for every field in the schema:
+//
+//	// email does not exist AND value is a string
+//	?filter[email][exists]=false&filter[value][type]=string
+//
+// Filters for fields within the same array of sub-documents (i.e.
+// authors.name and authors.email) are grouped with $elemMatch so that the
+// filters apply to the same element of the array... use SetMatchSubDocuments
+// to apply each filter independently instead.
+//
+// Object fields in the schema with type and coordinates properties are treated
+// as GeoJSON and support radius search ($nearSphere), bounding box and polygon
+// containment ($geoWithin) with longitude and latitude validation:
+//
+//	// within 500 meters of the point AND within the bounding box
+//	?filter[location][near]=-122.4,37.8,500&filter[area][within][box]=-10,-5,10,5
+//
+// A full-text search using the text index of the collection is provided with
+// the $search key (or the key set with SetTextSearchKey) along with optional
+// language, caseSensitive and diacriticSensitive options. The resulting $text
+// clause is always combined with the other filters using $and:
+//
+//	// $text: { $search: "coffee shop", $language: "en" }
+//	?filter[$search]=coffee shop&filter[$search][language]=en
 //
 // The supported bson types for filter/search are:
-// * array (strings only and not with $in operator unless sub items are strings)
+// * array (values are parsed as the bsonType of the items, with the all, size
+// and exact named operators... range comparisons apply to a single element
+// using $elemMatch)
+// * binData (UUID, hex or base64: prefixed values)
 // * bool
 // * date
 // * decimal
 // * double
 // * int
 // * long
+// * number (parsed as a double)
 // * object (field detection)
+// * objectId
 // * string
 // * timestamp
 //
-// The non-supported bson types for filter/search at this time
+// The non-supported bson types for filter/search at this time (a filter on a
+// field of one of these types is ignored unless the schema allows another type
+// that is able to parse the values)
 // * object (actual object comparison... only fields within the object are supported)
-// * array (non string data)
-// * binData
-// * objectId
 // * null
 // * regex
 // * dbPointer
@@ -67,99 +172,310 @@ func NewQueryBuilder(collection string, schema any, strictValidation ...bool) *Q
 // * minKey
 // * maxKey
 func (qb QueryBuilder) Filter(qo queryoptions.Options, o ...LogicalOperator) (bson.M, error) {
-	filter := bson.M{}
+	errs := ValidationErrors{}
 	oper := And
 
 	if len(o) > 0 {
 		oper = o[0]
 	}
 
-	if len(qo.Filter) > 0 {
-		for field, values := range qo.Filter {
-			var bsonType string
+	// separate any full-text search from the remaining filters
+	ff, text, err := qb.textFilter(qo.Filter)
+	errs.add(err)
 
-			// lookup the field
-			if bt, ok := qb.fieldTypes[field]; ok {
-				bsonType = bt
-			}
+	// build the clauses for each field and group
+	fields, groups, err := qb.filterClauses(ff, oper)
+	errs.add(err)
 
-			// check for strict field validation
-			if bsonType == "" && qb.strictValidation {
-				return nil, fmt.Errorf("field %s does not exist in collection %s", field, qb.collection)
-			}
+	// combine the clauses for each field and group
+	lo := qb.opts.fieldLogicalOperator()
+	filter := combineGroups(qb.matchSubDocuments(fields, lo), groups, lo)
 
-			switch bsonType {
-			case "array", "object", "string":
-				f := detectStringComparisonOperator(field, values, bsonType)
-				filter = combine(filter, f)
-			case "bool":
-				for _, value := range values {
-					bv, _ := strconv.ParseBool(value)
-					f := primitive.M{field: bv}
-					filter = combine(filter, f)
-				}
-			case "date", "timestamp":
-				f := detectDateComparisonOperator(field, values, oper)
-				filter = combine(filter, f)
-			case "decimal", "double", "int", "long":
-				f := detectNumericComparisonOperator(field, values, bsonType, oper)
-				filter = combine(filter, f)
-			}
-		}
+	// include the range clause for keyset (cursor) pagination
+	kf, err := qb.keysetFilter(qo.Sort, qo.Filter)
+	if err != nil {
+		errs.add(err)
+	}
+
+	filter = combine(filter, kf)
+
+	// the text search applies in addition to the other filters
+	filter = combine(filter, text)
+
+	// range comparisons for the same array field (i.e. filter[scores][gte]=80
+	// and filter[scores][lt]=90) apply to the same element
+	filter = qb.matchArrayElements(filter)
+
+	// $nearSphere is rejected by MongoDB within $or, $nor or $not
+	errs.add(validateNear(filter, false))
+
+	if err := withCollection(errs.err(), qb.collection); err != nil {
+		return nil, err
 	}
 
 	return filter, nil
 }
 
+// patterns returns the pattern builder for string filters on the field
+func (qb QueryBuilder) patterns(field string) patternBuilder {
+	return patternBuilder{
+		options:   qb.opts.regexOptions(field),
+		rawLength: qb.opts.rawRegexLength(),
+	}
+}
+
+// fieldFilter builds the clause for a single field using the operator detection
+// that is appropriate for the bsonType of the field
+func (qb QueryBuilder) fieldFilter(field string, values []string, lo LogicalOperator) (bson.M, error) {
+	// $not is only valid as a field operator... build the clause with $nor for
+	// multiple values and negate the operator expression for the field
+	if lo == Not {
+		f, err := qb.fieldFilter(field, values, Nor)
+		if err != nil || len(f) == 0 {
+			return f, err
+		}
+
+		return negateClause(f), nil
+	}
+
+	// check for a geospatial operator (i.e. filter[location][near]=lng,lat,max)
+	if fld, oper, ok := qb.geoPath(field); ok {
+		return qb.geoFilter(fld, oper, values)
+	}
+
+	// check for a named operator (i.e. filter[price][gte]=10)
+	if fld, oper, ok := qb.operatorPath(field); ok {
+		return qb.operatorFilter(fld, oper, values)
+	}
+
+	bsonType, err := qb.fieldType(field, values)
+	if err != nil || bsonType == "" {
+		return nil, err
+	}
+
+	// a field that allows several types uses the first type that is able to
+	// parse the values... a type that can not be filtered is skipped and the
+	// filter is ignored when no type remains
+	for _, bt := range qb.allowedTypes(field, bsonType) {
+		f, terr := qb.typedFilter(field, values, bt, lo)
+		if terr == nil {
+			return f, nil
+		}
+
+		if terr != errUnsupportedType && err == nil {
+			err = terr
+		}
+	}
+
+	return nil, err
+}
+
+// typedFilter builds the clause for a single field as the provided bsonType
+func (qb QueryBuilder) typedFilter(field string, values []string, bsonType string, lo LogicalOperator) (bson.M, error) {
+	var f bson.M
+	var err error
+
+	switch bsonType {
+	case "array", "object", "string":
+		return detectStringComparisonOperator(field, values, bsonType, lo, qb.patterns(field))
+	case "binData":
+		return detectBinaryComparisonOperator(field, values, lo, qb.opts.subtype(field))
+	case "bool":
+		return detectBoolComparisonOperator(field, values)
+	case "date":
+		f, err = detectDateComparisonOperator(field, values, bsonType, lo, qb.opts.exclusive(), qb.dateParser().parse)
+	case "decimal", "double", "int", "long", "number":
+		f, err = detectNumericComparisonOperator(field, values, bsonType, lo, qb.opts.exclusive())
+	case "objectId":
+		return detectObjectIDComparisonOperator(field, values, lo)
+	case "timestamp":
+		f, err = detectTimestampComparisonOperator(field, values, lo, qb.opts.exclusive(), qb.dateParser())
+	default:
+		// filters are ignored for a type that can not be filtered (i.e. regex)
+		return nil, errUnsupportedType
+	}
+
+	if err != nil || !qb.isArray(field) {
+		return f, err
+	}
+
+	// range comparisons for an array of dates or numbers apply to the same element
+	return qb.matchArrayElements(mergeRangeClauses(field, f)), nil
+}
+
+// dateParser returns a parser for date expressions using the clock and the
+// location from the options of the builder
+func (qb QueryBuilder) dateParser() dateParser {
+	return dateParser{now: qb.opts.now(), loc: qb.opts.timeLocation()}
+}
+
+// allowedTypes returns the types to try, in turn, when building the clause for
+// a field... for a field that allows several types in the schema (i.e.
+// ["int", "string"]), the types that are able to parse any value are tried last
+// so that a value such as 5 is compared as an int rather than a string, and
+// object is tried after array and string so that a value such as abc is
+// compared as a string rather than as the existence of a field within the
+// object
+func (qb QueryBuilder) allowedTypes(field string, bsonType string) []string {
+	union, ok := qb.unionTypes[field]
+	if !ok {
+		return []string{bsonType}
+	}
+
+	rank := map[string]int{
+		"array":  1,
+		"string": 1,
+		"object": 2,
+	}
+
+	types := append([]string{}, union...)
+	sort.SliceStable(types, func(i, j int) bool {
+		return rank[types[i]] < rank[types[j]]
+	})
+
+	return types
+}
+
+// fieldType looks up the bsonType of the field within the schema... an
+// UnknownFieldError is returned for an undefined field when strict validation
+// is enabled
+func (qb QueryBuilder) fieldType(field string, values []string) (string, error) {
+	if bsonType, ok := qb.fieldTypes[field]; ok {
+		return bsonType, nil
+	}
+
+	if qb.strictValidation {
+		return "", &UnknownFieldError{
+			Field:  field,
+			Value:  strings.Join(values, ","),
+			Reason: "field is not defined in the schema",
+		}
+	}
+
+	return "", nil
+}
+
 // FindOptions creates a mongo.FindOptions struct with pagination details, sorting,
-// and field projection instructions set as specified in the query options input
+// and field projection instructions set as specified in the query options input.
+// When strict validation is enabled, every unknown field in the projection and
+// sort is reported at once in a ValidationErrors collection.
 func (qb QueryBuilder) FindOptions(qo queryoptions.Options) (*options.FindOptions, error) {
+	errs := ValidationErrors{}
 	opts := options.Find()
 
 	// determine pagination for the options
 	qb.setPaginationOptions(qo.Page, opts)
 
 	// determine projection for the options
-	if err := qb.setProjectionOptions(qo.Fields, opts); err != nil {
-		return nil, err
-	}
+	errs.add(qb.setProjectionOptions(qo.Fields, opts))
 
 	// determine sorting for the options
-	if err := qb.setSortOptions(qo.Sort, opts); err != nil {
+	errs.add(qb.setSortOptions(qo.Sort, qo.Filter, opts))
+
+	// the relevance score of a text search is projected so that it is
+	// available in the results
+	if sort, ok := opts.Sort.(bson.D); ok && hasTextScore(sort) {
+		qb.setTextScoreProjection(opts)
+	}
+
+	// keyset (cursor) pagination replaces skip and requires a sort
+	if cur := qb.opts.cursorTokens(); cur != nil {
+		sort, _ := qb.keysetSort(qo.Sort, qo.Filter)
+
+		if sort, ok := opts.Sort.(bson.D); ok && hasTextScore(sort) {
+			errs.add(&UnsupportedOperatorError{
+				Field:    textScoreField,
+				Value:    textScoreField,
+				Operator: "$meta",
+				Reason:   "keyset pagination does not support sorting by the text score",
+			})
+		}
+
+		// reverse the sort to retrieve the documents before the cursor
+		if cur.After == "" {
+			for i, e := range sort {
+				sort[i].Value = -e.Value.(int)
+			}
+		}
+
+		opts.Skip = nil
+		opts.SetSort(sort)
+	}
+
+	if err := withCollection(errs.err(), qb.collection); err != nil {
 		return nil, err
 	}
 
 	return opts, nil
 }
 
+// CountOptions creates a mongo.CountOptions struct suitable for use with the Mongo
+// driver CountDocuments method to determine the total number of documents that
+// match the filter for the query options. The filter in the query options is
+// validated in the same manner as Filter, while sort, projection and
+// pagination are ignored so that the count is not limited to a single page.
+func (qb QueryBuilder) CountOptions(qo queryoptions.Options, o ...LogicalOperator) (*options.CountOptions, error) {
+	filter, err := qb.Filter(qo, o...)
+	if err != nil {
+		return nil, err
+	}
+
+	// CountDocuments uses an aggregation, which does not support $nearSphere
+	if err := withCollection(validateNear(filter, true), qb.collection); err != nil {
+		return nil, err
+	}
+
+	return options.Count(), nil
+}
+
 func (qb QueryBuilder) setPaginationOptions(pagination map[string]int, opts *options.FindOptions) {
+	limit, skip := paginationBounds(pagination)
+
+	if limit != nil {
+		opts.SetLimit(*limit)
+	}
+
+	if skip != nil {
+		opts.SetSkip(*skip)
+	}
+}
+
+// paginationBounds determines the limit and skip for either the limit/offset
+// (or limit/skip) or the page/size pagination styles
+func paginationBounds(pagination map[string]int) (*int64, *int64) {
+	var limit, skip *int64
+
 	// check for limit
-	if limit, ok := pagination["limit"]; ok {
-		opts.SetLimit(int64(limit))
+	if l, ok := pagination["limit"]; ok {
+		limit = &[]int64{int64(l)}[0]
 
 		// check for offset (once limit is set)
 		if offset, ok := pagination["offset"]; ok {
-			opts.SetSkip(int64(offset))
+			skip = &[]int64{int64(offset)}[0]
 		}
 
 		// check for skip (once limit is set)
-		if skip, ok := pagination["skip"]; ok {
-			opts.SetSkip(int64(skip))
+		if s, ok := pagination["skip"]; ok {
+			skip = &[]int64{int64(s)}[0]
 		}
 	}
 
 	// check for page and size
 	if size, ok := pagination["size"]; ok {
-		opts.SetLimit(int64(size))
+		limit = &[]int64{int64(size)}[0]
 
 		// set skip (requires understanding of size)
 		if page, ok := pagination["page"]; ok {
-			opts.SetSkip(int64(page * size))
+			skip = &[]int64{int64(page * size)}[0]
 		}
 	}
+
+	return limit, skip
 }
 
 func (qb QueryBuilder) setProjectionOptions(fields []string, opts *options.FindOptions) error {
+	errs := ValidationErrors{}
+
 	// set field projections option
 	if len(fields) > 0 {
 		prj := map[string]int{}
@@ -167,21 +483,29 @@ func (qb QueryBuilder) setProjectionOptions(fields []string, opts *options.FindO
 			val := 1
 
 			// handle when the first char is a - (don't display field in result)
-			if field[0:1] == "-" {
+			if strings.HasPrefix(field, "-") {
 				field = field[1:]
 				val = 0
 			}
 
 			// handle scenarios where the first char is a + (redundant)
-			if len(field) > 0 && field[0:1] == "+" {
-				field = field[1:]
+			field = strings.TrimPrefix(field, "+")
+
+			// an empty field (i.e. fields=a,,b or fields=-) is ignored
+			if field == "" {
+				continue
 			}
 
 			// lookup field in the fieldTypes dictionary if strictValidation is true
 			if qb.strictValidation {
 				if _, ok := qb.fieldTypes[field]; !ok {
 					// we have a problem
-					return fmt.Errorf("field %s does not exist in collection %s", field, qb.collection)
+					errs.add(&UnknownFieldError{
+						Field:  field,
+						Value:  field,
+						Reason: "projected field is not defined in the schema",
+					})
+					continue
 				}
 			}
 
@@ -195,37 +519,90 @@ func (qb QueryBuilder) setProjectionOptions(fields []string, opts *options.FindO
 		}
 	}
 
-	return nil
+	return errs.err()
 }
 
-func (qb QueryBuilder) setSortOptions(fields []string, opts *options.FindOptions) error {
-	if len(fields) > 0 {
-		sort := map[string]int{}
-		for _, field := range fields {
-			val := 1
+func (qb QueryBuilder) setSortOptions(fields []string, filter map[string][]string, opts *options.FindOptions) error {
+	sort, err := qb.sortKeys(fields, filter)
 
-			if field[0:1] == "-" {
-				field = field[1:]
-				val = -1
-			}
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	}
 
-			if field[0:1] == "+" {
-				field = field[1:]
+	return err
+}
+
+// sortKeys builds an ordered sort document that retains the order in which the
+// fields were requested, appending the sort tiebreaker when one is configured...
+// the filter determines whether score refers to the relevance of a text search
+func (qb QueryBuilder) sortKeys(fields []string, filter map[string][]string) (bson.D, error) {
+	errs := ValidationErrors{}
+	sort := bson.D{}
+	seen := map[string]bool{}
+
+	for _, field := range fields {
+		val := 1
+
+		if strings.HasPrefix(field, "-") {
+			field = field[1:]
+			val = -1
+		}
+
+		field = strings.TrimPrefix(field, "+")
+
+		// an empty field (i.e. sort=name, or sort=-) is ignored
+		if field == "" {
+			continue
+		}
+
+		// the relevance score of a text search is always sorted descending
+		if qb.isTextScore(field, filter) {
+			if !seen[field] {
+				seen[field] = true
+				sort = append(sort, bson.E{Key: field, Value: textScore()})
 			}
 
-			// lookup field in the fieldTypes dictionary if strictValidation is true
-			if qb.strictValidation {
-				if _, ok := qb.fieldTypes[field]; !ok {
-					// we have a problem
-					return fmt.Errorf("field %s does not exist in collection %s", field, qb.collection)
-				}
+			continue
+		}
+
+		// lookup field in the fieldTypes dictionary if strictValidation is true
+		if qb.strictValidation {
+			if _, ok := qb.fieldTypes[field]; !ok {
+				// we have a problem
+				errs.add(&UnknownFieldError{
+					Field:  field,
+					Value:  field,
+					Reason: "sort field is not defined in the schema",
+				})
+				continue
 			}
+		}
 
-			sort[field] = val
+		// only the first mention of a field is meaningful
+		if seen[field] {
+			continue
 		}
 
-		opts.SetSort(sort)
+		seen[field] = true
+		sort = append(sort, bson.E{Key: field, Value: val})
 	}
 
-	return nil
+	// append the tiebreaker so that pagination remains stable
+	if tb := qb.opts.tiebreaker(); tb != "" && !seen[tb] {
+		sort = append(sort, bson.E{Key: tb, Value: 1})
+	}
+
+	return sort, errs.err()
+}
+
+// sortedKeys returns the keys of the map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
 }
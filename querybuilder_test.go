@@ -2,6 +2,9 @@ package querybuilder
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
 	"time"
@@ -546,10 +549,25 @@ func Test_NewQueryBuilder(t *testing.T) {
 	}
 }
 
+func Test_NewQueryBuilder_ArrayFields(t *testing.T) {
+	qb := NewQueryBuilder("test", testSchema)
+
+	want := map[string]bool{
+		"childArray":            true,
+		"childStringArray":      true,
+		"childStructure.fieldA": true,
+	}
+
+	if !reflect.DeepEqual(qb.arrayFields, want) {
+		t.Errorf("NewQueryBuilder(), qb.arrayFields = %v, want %v", qb.arrayFields, want)
+	}
+}
+
 func TestQueryBuilder_Filter(t *testing.T) {
 	type fields struct {
 		collection       string
 		fieldTypes       map[string]string
+		opts             *queryBuilderOptions
 		strictValidation bool
 	}
 	type args struct {
@@ -618,7 +636,7 @@ func TestQueryBuilder_Filter(t *testing.T) {
 				qs: "filter[doVal]=0.000000000000000000000000000000009&filter[deVal]=10.01&filter[iVal]=2147483647&filter[lVal]=9223372036854775807",
 			},
 			want: bson.M{
-				"deVal": float32(10.01),
+				"deVal": mustDecimal128("10.01"),
 				"doVal": float64(0.000000000000000000000000000000009),
 				"iVal":  int32(2147483647),
 				"lVal":  int64(9223372036854775807),
@@ -645,7 +663,7 @@ func TestQueryBuilder_Filter(t *testing.T) {
 				}},
 				"iVal2": bson.D{bson.E{
 					Key:   "$in",
-					Value: bson.A{float32(1.1), float32(2.2), float32(3.3)},
+					Value: bson.A{mustDecimal128("1.1"), mustDecimal128("2.2"), mustDecimal128("3.3")},
 				}},
 			},
 			wantErr: false,
@@ -798,11 +816,14 @@ func TestQueryBuilder_Filter(t *testing.T) {
 				qs: "filter[dVal1]=2020-01-01T12:00:00.000Z&filter[dVal2]=2021-02-16T02:04:05.000Z&filter[dVal3]=2021-02-16T02:04:05.000Z,2020-01-01T12:00:00.000Z",
 			},
 			want: bson.M{
-				"dVal1": time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC),
-				"dVal2": time.Date(2021, time.February, 16, 2, 4, 5, 0, time.UTC),
+				"dVal1": primitive.Timestamp{T: uint32(time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC).Unix())},
+				"dVal2": primitive.Timestamp{T: uint32(time.Date(2021, time.February, 16, 2, 4, 5, 0, time.UTC).Unix())},
 				"dVal3": bson.D{bson.E{
-					Key:   "$in",
-					Value: bson.A{time.Date(2021, time.February, 16, 2, 4, 5, 0, time.UTC), time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)},
+					Key: "$in",
+					Value: bson.A{
+						primitive.Timestamp{T: uint32(time.Date(2021, time.February, 16, 2, 4, 5, 0, time.UTC).Unix())},
+						primitive.Timestamp{T: uint32(time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC).Unix())},
+					},
 				}},
 			},
 			wantErr: false,
@@ -991,14 +1012,14 @@ func TestQueryBuilder_Filter(t *testing.T) {
 						Key: "iVal2",
 						Value: bson.D{bson.E{
 							Key:   "$gt",
-							Value: float32(1.1),
+							Value: mustDecimal128("1.1"),
 						}},
 					}},
 					bson.D{bson.E{
 						Key: "iVal2",
 						Value: bson.D{bson.E{
 							Key:   "$lte",
-							Value: float32(2.2),
+							Value: mustDecimal128("2.2"),
 						}},
 					}},
 				},
@@ -1094,14 +1115,14 @@ func TestQueryBuilder_Filter(t *testing.T) {
 						Key: "iVal2",
 						Value: bson.D{bson.E{
 							Key:   "$gt",
-							Value: float32(1.1),
+							Value: mustDecimal128("1.1"),
 						}},
 					}},
 					bson.D{bson.E{
 						Key: "iVal2",
 						Value: bson.D{bson.E{
 							Key:   "$lte",
-							Value: float32(2.2),
+							Value: mustDecimal128("2.2"),
 						}},
 					}},
 					bson.D{bson.E{
@@ -1109,9 +1130,9 @@ func TestQueryBuilder_Filter(t *testing.T) {
 						Value: bson.D{bson.E{
 							Key: "$in",
 							Value: bson.A{
-								float32(1.3),
-								float32(1.4),
-								float32(1.5),
+								mustDecimal128("1.3"),
+								mustDecimal128("1.4"),
+								mustDecimal128("1.5"),
 							},
 						}},
 					}},
@@ -1185,304 +1206,2469 @@ func TestQueryBuilder_Filter(t *testing.T) {
 				},
 			},
 			want: bson.M{
-				"$or": bson.A{
-					bson.D{bson.E{
-						Key: "iVal1",
-						Value: bson.D{bson.E{
-							Key:   "$gte",
-							Value: int32(1),
+				"$and": bson.A{
+					bson.M{"$or": bson.A{
+						bson.D{bson.E{
+							Key: "iVal1",
+							Value: bson.D{bson.E{
+								Key:   "$gte",
+								Value: int32(1),
+							}},
 						}},
-					}},
-					bson.D{bson.E{
-						Key: "iVal1",
-						Value: bson.D{bson.E{
-							Key:   "$lt",
-							Value: int32(5),
+						bson.D{bson.E{
+							Key: "iVal1",
+							Value: bson.D{bson.E{
+								Key:   "$lt",
+								Value: int32(5),
+							}},
 						}},
-					}},
-					bson.D{bson.E{
-						Key: "iVal1",
-						Value: bson.D{bson.E{
-							Key:   "$ne",
-							Value: int32(3),
+						bson.D{bson.E{
+							Key: "iVal1",
+							Value: bson.D{bson.E{
+								Key:   "$ne",
+								Value: int32(3),
+							}},
 						}},
 					}},
-					bson.D{bson.E{
-						Key: "iVal2",
-						Value: bson.D{bson.E{
-							Key:   "$gt",
-							Value: float32(1.1),
+					bson.M{"$or": bson.A{
+						bson.D{bson.E{
+							Key: "iVal2",
+							Value: bson.D{bson.E{
+								Key:   "$gt",
+								Value: mustDecimal128("1.1"),
+							}},
 						}},
-					}},
-					bson.D{bson.E{
-						Key: "iVal2",
-						Value: bson.D{bson.E{
-							Key:   "$lte",
-							Value: float32(2.2),
+						bson.D{bson.E{
+							Key: "iVal2",
+							Value: bson.D{bson.E{
+								Key:   "$lte",
+								Value: mustDecimal128("2.2"),
+							}},
 						}},
 					}},
 				},
 			},
 			wantErr: false,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			qb := QueryBuilder{
-				collection:       tt.fields.collection,
-				fieldTypes:       tt.fields.fieldTypes,
-				strictValidation: tt.fields.strictValidation,
-			}
-
-			qo, err := queryoptions.FromQuerystring(tt.args.qs)
-			if err != nil {
-				t.Errorf("options.FromQuerystring() error = %v", err)
-				return
-			}
-
-			got, err := qb.Filter(qo, tt.args.lo...)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-
-			// check to see if it matches expectations
-			if !reflect.DeepEqual(got, tt.want) {
-				// values do not match
-				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestQueryBuilder_FindOptions(t *testing.T) {
-	var el int64 = 100
-
-	type fields struct {
-		collection       string
-		fieldTypes       map[string]string
-		strictValidation bool
-	}
-	type args struct {
-		qo queryoptions.Options
-	}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    *options.FindOptions
-		wantErr bool
-	}{
 		{
-			name: "should properly determine Limit options with query options defined limit",
+			name: "should properly handle objectId types",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"oid1": "objectId",
+					"oid2": "objectId",
+					"oid3": "objectId",
+					"oid4": "objectId",
+					"oid5": "objectId",
+					"oid6": "objectId",
+				},
 				strictValidation: false,
 			},
 			args: args{
-				qo: queryoptions.Options{
-					Page: map[string]int{
-						"limit": 100,
-					},
-				},
+				qs: "filter[oid1]=5f1b7e3e9d9b4b0a8c8b4567&filter[oid2]=5f1b7e3e9d9b4b0a8c8b4567,5f1b7e3e9d9b4b0a8c8b4568&filter[oid3]=!=5f1b7e3e9d9b4b0a8c8b4567&filter[oid4]=-5f1b7e3e9d9b4b0a8c8b4567&filter[oid5]=null&filter[oid6]=-null",
 			},
-			want: &options.FindOptions{
-				Limit: &el,
+			want: bson.M{
+				"oid1": mustObjectID("5f1b7e3e9d9b4b0a8c8b4567"),
+				"oid2": bson.D{bson.E{
+					Key: "$in",
+					Value: bson.A{
+						mustObjectID("5f1b7e3e9d9b4b0a8c8b4567"),
+						mustObjectID("5f1b7e3e9d9b4b0a8c8b4568"),
+					},
+				}},
+				"oid3": bson.D{bson.E{
+					Key:   "$ne",
+					Value: mustObjectID("5f1b7e3e9d9b4b0a8c8b4567"),
+				}},
+				"oid4": bson.D{bson.E{
+					Key:   "$ne",
+					Value: mustObjectID("5f1b7e3e9d9b4b0a8c8b4567"),
+				}},
+				"oid5": nil,
+				"oid6": bson.D{bson.E{
+					Key:   "$ne",
+					Value: nil,
+				}},
 			},
 			wantErr: false,
 		},
 		{
-			name: "should properly determine Limit options with query options defined size",
+			name: "should error with invalid objectId hex values",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"oid1": "objectId",
+				},
 				strictValidation: false,
 			},
 			args: args{
-				qo: queryoptions.Options{
-					Page: map[string]int{
-						"size": 100,
-					},
-				},
-			},
-			want: &options.FindOptions{
-				Limit: &el,
+				qs: "filter[oid1]=5f1b7e3e9d9b4b0a8c8b4567,notanobjectid",
 			},
-			wantErr: false,
+			want:    nil,
+			wantErr: true,
 		},
 		{
-			name: "should properly determine Skip options with query options defined limit and offset",
+			name: "should combine field clauses with $or when the field operator is Or",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"name":        "string",
+					"description": "string",
+				},
+				opts:             QueryBuilderOptions().SetFieldOperator(Or),
 				strictValidation: false,
 			},
 			args: args{
-				qo: queryoptions.Options{
-					Page: map[string]int{
-						"limit":  100,
-						"offset": 100,
-					},
-				},
+				qs: "filter[name]=*term*&filter[description]=*term*",
 			},
-			want: &options.FindOptions{
-				Limit: &el,
-				Skip:  &el,
+			want: bson.M{
+				"$or": bson.A{
+					bson.M{"description": primitive.Regex{
+						Pattern: "term",
+						Options: "i",
+					}},
+					bson.M{"name": primitive.Regex{
+						Pattern: "term",
+						Options: "i",
+					}},
+				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "should properly determine Skip options with query options defined limit and skip",
+			name: "should keep the per field logical operator within each clause when the field operator is Nor",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"iVal1": "int",
+					"sVal1": "string",
+				},
+				opts:             QueryBuilderOptions().SetFieldOperator(Nor),
 				strictValidation: false,
 			},
 			args: args{
-				qo: queryoptions.Options{
-					Page: map[string]int{
-						"limit": 100,
-						"skip":  100,
-					},
-				},
+				qs: "filter[iVal1]=>=1,<5&filter[sVal1]=value",
 			},
-			want: &options.FindOptions{
-				Limit: &el,
-				Skip:  &el,
+			want: bson.M{
+				"$nor": bson.A{
+					bson.M{"$and": bson.A{
+						bson.D{bson.E{
+							Key: "iVal1",
+							Value: bson.D{bson.E{
+								Key:   "$gte",
+								Value: int32(1),
+							}},
+						}},
+						bson.D{bson.E{
+							Key: "iVal1",
+							Value: bson.D{bson.E{
+								Key:   "$lt",
+								Value: int32(5),
+							}},
+						}},
+					}},
+					bson.M{"sVal1": "value"},
+				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "should properly determine Skip and Size options with query options defined page and size",
+			name: "should not wrap a single field clause in $or",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+				},
+				opts:             QueryBuilderOptions().SetFieldOperator(Or),
 				strictValidation: false,
 			},
 			args: args{
-				qo: queryoptions.Options{
-					Page: map[string]int{
-						"page": 1,
-						"size": 100,
-					},
-				},
+				qs: "filter[sVal1]=value",
 			},
-			want: &options.FindOptions{
-				Limit: &el,
-				Skip:  &el,
+			want: bson.M{
+				"sVal1": "value",
 			},
 			wantErr: false,
 		},
 		{
-			name: "should properly determine projection fields when provided",
+			name: "should collapse multiple negated values into $nin",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"status": "string",
+					"iVal":   "int",
+					"dVal":   "date",
+					"oid":    "objectId",
+				},
 				strictValidation: false,
 			},
 			args: args{
-				// notice use of + before fieldC to validate + prefix to field name
-				qo: queryoptions.Options{
-					Fields: []string{"fieldA", "fieldB", "+fieldC"},
-				},
+				qs: "filter[status]=-archived&filter[status]=!=deleted&filter[iVal]=!=1,!=-2&filter[dVal]=-2021-01-01,!=2021-01-02&filter[oid]=-5f1b7e3e9d9b4b0a8c8b4567,-5f1b7e3e9d9b4b0a8c8b4568",
 			},
-			want: &options.FindOptions{
-				Projection: map[string]int{
-					"fieldA": 1,
-					"fieldB": 1,
-					"fieldC": 1,
+			want: bson.M{
+				"status": bson.D{bson.E{
+					Key:   "$nin",
+					Value: bson.A{"archived", "deleted"},
+				}},
+				"iVal": bson.D{bson.E{
+					Key:   "$nin",
+					Value: bson.A{int32(1), int32(-2)},
+				}},
+				"$and": bson.A{
+					bson.D{bson.E{
+						Key: "$nor",
+						Value: bson.A{
+							bson.D{bson.E{
+								Key: "dVal",
+								Value: bson.D{
+									bson.E{Key: "$gte", Value: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+									bson.E{Key: "$lt", Value: time.Date(2021, time.January, 2, 0, 0, 0, 0, time.UTC)},
+								},
+							}},
+							bson.D{bson.E{
+								Key: "dVal",
+								Value: bson.D{
+									bson.E{Key: "$gte", Value: time.Date(2021, time.January, 2, 0, 0, 0, 0, time.UTC)},
+									bson.E{Key: "$lt", Value: time.Date(2021, time.January, 3, 0, 0, 0, 0, time.UTC)},
+								},
+							}},
+						},
+					}},
 				},
+				"oid": bson.D{bson.E{
+					Key: "$nin",
+					Value: bson.A{
+						mustObjectID("5f1b7e3e9d9b4b0a8c8b4567"),
+						mustObjectID("5f1b7e3e9d9b4b0a8c8b4568"),
+					},
+				}},
 			},
 			wantErr: false,
 		},
 		{
-			name: "should properly determine excluded fields in projection when provided",
+			name: "should combine positive and negated values using the LogicalOperator",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"status": "string",
+				},
 				strictValidation: false,
 			},
 			args: args{
-				qo: queryoptions.Options{
-					Fields: []string{"-fieldA"},
+				qs: "filter[status]=open,-archived,pending,-deleted",
+				lo: []LogicalOperator{
+					Or,
 				},
 			},
-			want: &options.FindOptions{
-				Projection: map[string]int{
-					"fieldA": 0,
-				},
+			want: bson.M{
+				"$and": bson.A{bson.M{"$or": bson.A{
+					bson.D{bson.E{
+						Key: "status",
+						Value: bson.D{bson.E{
+							Key:   "$nin",
+							Value: bson.A{"archived", "deleted"},
+						}},
+					}},
+					bson.D{bson.E{
+						Key: "status",
+						Value: bson.D{bson.E{
+							Key:   "$in",
+							Value: bson.A{"open", "pending"},
+						}},
+					}},
+				}}},
 			},
 			wantErr: false,
 		},
 		{
-			name: "should properly error when providing a field in projection that does not exist and strict validation is true",
+			name: "should combine range operators with multiple negated values",
 			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
-				strictValidation: true,
-			},
-			args: args{
-				qo: queryoptions.Options{
-					Fields: []string{"-fieldA"},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"iVal": "int",
 				},
-			},
-			want:    nil,
-			wantErr: true,
-		},
-		{
-			name: "should properly sort when sort details are provided",
-			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
 				strictValidation: false,
 			},
 			args: args{
-				// notice the use of + and - as field prefixes below
-				qo: queryoptions.Options{
+				qs: "filter[iVal]=>=1,!=3,<10,!=5",
+			},
+			want: bson.M{
+				"$and": bson.A{
+					bson.D{bson.E{
+						Key: "iVal",
+						Value: bson.D{bson.E{
+							Key:   "$gte",
+							Value: int32(1),
+						}},
+					}},
+					bson.D{bson.E{
+						Key: "iVal",
+						Value: bson.D{bson.E{
+							Key:   "$nin",
+							Value: bson.A{int32(3), int32(5)},
+						}},
+					}},
+					bson.D{bson.E{
+						Key: "iVal",
+						Value: bson.D{bson.E{
+							Key:   "$lt",
+							Value: int32(10),
+						}},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly handle range literals for numeric and date values",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"iVal1": "int",
+					"iVal2": "double",
+					"iVal3": "decimal",
+					"dVal1": "date",
+					"dVal2": "date",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[iVal1]=-10..20&filter[iVal2]=..2.5&filter[iVal3]=1.5..&filter[dVal1]=2024-01-01..2024-02-01&filter[dVal2]=2024-01-01T12:00:00Z..",
+			},
+			want: bson.M{
+				"iVal1": bson.D{
+					bson.E{Key: "$gte", Value: int32(-10)},
+					bson.E{Key: "$lte", Value: int32(20)},
+				},
+				"iVal2": bson.D{
+					bson.E{Key: "$lte", Value: float64(2.5)},
+				},
+				"iVal3": bson.D{
+					bson.E{Key: "$gte", Value: mustDecimal128("1.5")},
+				},
+				"dVal1": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+					bson.E{Key: "$lt", Value: time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC)},
+				},
+				"dVal2": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should use exclusive bounds for range literals when configured",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"iVal1": "int",
+				},
+				opts:             QueryBuilderOptions().SetExclusiveRanges(true),
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[iVal1]=10..20,30",
+				lo: []LogicalOperator{
+					Or,
+				},
+			},
+			want: bson.M{
+				"$and": bson.A{bson.M{"$or": bson.A{
+					bson.D{bson.E{
+						Key: "iVal1",
+						Value: bson.D{
+							bson.E{Key: "$gt", Value: int32(10)},
+							bson.E{Key: "$lt", Value: int32(20)},
+						},
+					}},
+					bson.D{bson.E{
+						Key: "iVal1",
+						Value: bson.D{bson.E{
+							Key:   "$in",
+							Value: bson.A{int32(30)},
+						}},
+					}},
+				}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should error when a range literal is invalid",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"iVal1": "int",
+					"dVal1": "date",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[iVal1]=..&filter[dVal1]=2024-01-01..abc",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should properly handle relative and partial date expressions",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"dVal1": "date",
+					"dVal2": "date",
+					"dVal3": "date",
+					"dVal4": "date",
+					"dVal5": "date",
+					"dVal6": "date",
+					"dVal7": "date",
+				},
+				opts: QueryBuilderOptions().SetClock(func() time.Time {
+					return time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC)
+				}),
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[dVal1]=>now-7d&filter[dVal2]=today&filter[dVal3]=2024-05&filter[dVal4]=<=2024&filter[dVal5]=>=startOfMonth&filter[dVal6]=startOfWeek..yesterday&filter[dVal7]=!=2024-05",
+			},
+			want: bson.M{
+				"dVal1": bson.D{
+					bson.E{Key: "$gt", Value: time.Date(2024, time.May, 8, 12, 0, 0, 0, time.UTC)},
+				},
+				"dVal2": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.May, 15, 0, 0, 0, 0, time.UTC)},
+					bson.E{Key: "$lt", Value: time.Date(2024, time.May, 16, 0, 0, 0, 0, time.UTC)},
+				},
+				"dVal3": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)},
+					bson.E{Key: "$lt", Value: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+				},
+				"dVal4": bson.D{
+					bson.E{Key: "$lt", Value: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+				},
+				"dVal5": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)},
+				},
+				"dVal6": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC)},
+					bson.E{Key: "$lt", Value: time.Date(2024, time.May, 15, 0, 0, 0, 0, time.UTC)},
+				},
+				"dVal7": bson.D{bson.E{
+					Key: "$not",
+					Value: bson.D{
+						bson.E{Key: "$gte", Value: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)},
+						bson.E{Key: "$lt", Value: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+					},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should match any of multiple relative date expressions",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"dVal1": "date",
+				},
+				opts: QueryBuilderOptions().SetClock(func() time.Time {
+					return time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC)
+				}),
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[dVal1]=yesterday,startOfYear+1M",
+			},
+			want: bson.M{
+				"$and": bson.A{
+					bson.D{bson.E{
+						Key: "$or",
+						Value: bson.A{
+							bson.D{bson.E{
+								Key: "dVal1",
+								Value: bson.D{bson.E{
+									Key:   "$in",
+									Value: bson.A{time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+								}},
+							}},
+							bson.D{bson.E{
+								Key: "dVal1",
+								Value: bson.D{
+									bson.E{Key: "$gte", Value: time.Date(2024, time.May, 14, 0, 0, 0, 0, time.UTC)},
+									bson.E{Key: "$lt", Value: time.Date(2024, time.May, 15, 0, 0, 0, 0, time.UTC)},
+								},
+							}},
+						},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should error when a date expression can not be parsed",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"dVal1": "date",
+					"dVal2": "date",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[dVal1]=now-7x&filter[dVal2]=2024-13",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should interpret dates without a time zone in the configured location",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"dVal1": "date",
+					"dVal2": "date",
+					"dVal3": "date",
+					"dVal4": "date",
+				},
+				opts: QueryBuilderOptions().
+					SetLocation(time.FixedZone("UTC-5", -5*60*60)).
+					SetClock(func() time.Time {
+						return time.Date(2024, time.March, 11, 2, 0, 0, 0, time.UTC)
+					}),
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[dVal1]=2024-03-10&filter[dVal2]=>=2024-03-10T08:00:00&filter[dVal3]=today&filter[dVal4]=>2024-03-10T08:00:00Z",
+			},
+			want: bson.M{
+				"dVal1": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.March, 10, 5, 0, 0, 0, time.UTC)},
+					bson.E{Key: "$lt", Value: time.Date(2024, time.March, 11, 5, 0, 0, 0, time.UTC)},
+				},
+				"dVal2": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.March, 10, 13, 0, 0, 0, time.UTC)},
+				},
+				"dVal3": bson.D{
+					bson.E{Key: "$gte", Value: time.Date(2024, time.March, 10, 5, 0, 0, 0, time.UTC)},
+					bson.E{Key: "$lt", Value: time.Date(2024, time.March, 11, 5, 0, 0, 0, time.UTC)},
+				},
+				"dVal4": bson.D{
+					bson.E{Key: "$gt", Value: time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC)},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly handle timestamp T,I pairs, epoch seconds and operators",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"ts1": "timestamp",
+					"ts2": "timestamp",
+					"ts3": "timestamp",
+					"ts4": "timestamp",
+					"ts5": "timestamp",
+					"ts6": "timestamp",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[ts1]=(1700000000,5)&filter[ts2]=>=1700000000&filter[ts3]=(1700000000,1),(1700000001,2)&filter[ts4]=-(1700000000,1),!=(1700000000,2)&filter[ts5]=(1700000000,1)..1700000100&filter[ts6]=2024-05",
+			},
+			want: bson.M{
+				"ts1": primitive.Timestamp{T: 1700000000, I: 5},
+				"ts2": bson.D{bson.E{
+					Key:   "$gte",
+					Value: primitive.Timestamp{T: 1700000000},
+				}},
+				"ts3": bson.D{bson.E{
+					Key: "$in",
+					Value: bson.A{
+						primitive.Timestamp{T: 1700000000, I: 1},
+						primitive.Timestamp{T: 1700000001, I: 2},
+					},
+				}},
+				"ts4": bson.D{bson.E{
+					Key: "$nin",
+					Value: bson.A{
+						primitive.Timestamp{T: 1700000000, I: 1},
+						primitive.Timestamp{T: 1700000000, I: 2},
+					},
+				}},
+				"ts5": bson.D{
+					bson.E{Key: "$gte", Value: primitive.Timestamp{T: 1700000000, I: 1}},
+					bson.E{Key: "$lte", Value: primitive.Timestamp{T: 1700000100, I: math.MaxUint32}},
+				},
+				"ts6": bson.D{
+					bson.E{Key: "$gte", Value: primitive.Timestamp{T: uint32(time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC).Unix())}},
+					bson.E{Key: "$lt", Value: primitive.Timestamp{T: uint32(time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC).Unix())}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should error when a timestamp can not be parsed",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"ts1": "timestamp",
+					"ts2": "timestamp",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[ts1]=(1700000000,abc)&filter[ts2]=yesterday-ish",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should escape regex metacharacters in string patterns",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+					"sVal2": "string",
+					"sVal3": "string",
+					"sVal4": "string",
+				},
+			},
+			args: args{
+				qs: `filter[sVal1]=*a.b(*&filter[sVal2]=[x]*&filter[sVal3]=*$5&filter[sVal4]="a|b"`,
+			},
+			want: bson.M{
+				"sVal1": primitive.Regex{Pattern: `a\.b\(`, Options: "i"},
+				"sVal2": primitive.Regex{Pattern: `^\[x\]`, Options: "i"},
+				"sVal3": primitive.Regex{Pattern: `\$5$`, Options: "i"},
+				"sVal4": primitive.Regex{Pattern: `^a\|b$`, Options: ""},
+			},
+		},
+		{
+			name: "should compare a raw regex as a string when not enabled",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+				},
+			},
+			args: args{
+				qs: `filter[sVal1]=/^ab.*$/i`,
+			},
+			want: bson.M{
+				"sVal1": "/^ab.*$/i",
+			},
+		},
+		{
+			name: "should use raw regex values when enabled",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+					"sVal2": "string",
+				},
+				opts: QueryBuilderOptions().SetRawRegex(16),
+			},
+			args: args{
+				qs: `filter[sVal1]=/^ab.*$/i&filter[sVal2]=/^x/,y`,
+			},
+			want: bson.M{
+				"sVal1": primitive.Regex{Pattern: "^ab.*$", Options: "i"},
+				"sVal2": bson.D{bson.E{Key: "$in", Value: bson.A{
+					primitive.Regex{Pattern: "^x", Options: ""},
+					"y",
+				}}},
+			},
+		},
+		{
+			name: "should error for a raw regex that is too long or invalid",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+					"sVal2": "string",
+				},
+				opts: QueryBuilderOptions().SetRawRegex(4),
+			},
+			args: args{
+				qs: `filter[sVal1]=/abcdef/&filter[sVal2]=/(a/`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "should use case sensitive patterns for the builder or a field",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+					"sVal2": "string",
+					"sVal3": "string",
+				},
+				opts: QueryBuilderOptions().SetCaseSensitive(true).SetCaseSensitive(false, "sVal2"),
+			},
+			args: args{
+				qs: `filter[sVal1]=AB*&filter[sVal2]=*cd&filter[sVal3][contains]=Ef`,
+			},
+			want: bson.M{
+				"sVal1": primitive.Regex{Pattern: "^AB", Options: ""},
+				"sVal2": primitive.Regex{Pattern: "cd$", Options: "i"},
+				"sVal3": primitive.Regex{Pattern: "Ef", Options: ""},
+			},
+		},
+		{
+			name: "should build a $text clause for a text search",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"category": "string",
+				},
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[$search]=coffee+shop&filter[$search][language]=es&filter[$search][caseSensitive]=true&filter[$search][diacriticSensitive]=false&filter[category]=food",
+			},
+			want: bson.M{
+				"$text": bson.D{
+					bson.E{Key: "$search", Value: "coffee shop"},
+					bson.E{Key: "$caseSensitive", Value: true},
+					bson.E{Key: "$diacriticSensitive", Value: false},
+					bson.E{Key: "$language", Value: "es"},
+				},
+				"category": "food",
+			},
+		},
+		{
+			name: "should use the configured text search key",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"category": "string",
+				},
+				opts:             QueryBuilderOptions().SetTextSearchKey("q"),
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[q]=coffee,tea&filter[category]=food,drink",
+				lo: []LogicalOperator{Or},
+			},
+			want: bson.M{
+				"$text": bson.D{
+					bson.E{Key: "$search", Value: "coffee tea"},
+				},
+				"category": bson.D{bson.E{Key: "$in", Value: bson.A{"food", "drink"}}},
+			},
+		},
+		{
+			name: "should error for text search options that are not valid or not at the top level",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"category": "string",
+				},
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[$search]=coffee&filter[$search][caseSensitive]=maybe&filter[$search][score]=1&filter[or][0][$search]=tea",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should properly handle binData types",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"bVal1": "binData",
+					"bVal2": "binData",
+					"bVal3": "binData",
+					"bVal4": "binData",
+				},
+				opts:             QueryBuilderOptions().SetBinarySubtype(0x80, "bVal4"),
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[bVal1]=123e4567-e89b-12d3-a456-426614174000&filter[bVal2]=!=deadbeef&filter[bVal3]=-00ff,-base64:aGVsbG8&filter[bVal4]=0102,null",
+			},
+			want: bson.M{
+				"bVal1": primitive.Binary{
+					Subtype: bson.TypeBinaryUUID,
+					Data:    []byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+				},
+				"bVal2": bson.D{bson.E{Key: "$ne", Value: primitive.Binary{Data: []byte{0xde, 0xad, 0xbe, 0xef}}}},
+				"bVal3": bson.D{bson.E{Key: "$nin", Value: bson.A{
+					primitive.Binary{Data: []byte{0x00, 0xff}},
+					primitive.Binary{Data: []byte("hello")},
+				}}},
+				"bVal4": bson.D{bson.E{Key: "$in", Value: bson.A{
+					primitive.Binary{Subtype: 0x80, Data: []byte{0x01, 0x02}},
+					nil,
+				}}},
+			},
+		},
+		{
+			name: "should error for binData range comparisons and values that can not be parsed",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"bVal1": "binData",
+					"bVal2": "binData",
+				},
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[bVal1]=>deadbeef&filter[bVal2]=-_8",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should not merge the $or of multiple fields using the LogicalOperator",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"n": "int",
+					"s": "string",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[n]=%3E1,%3C3&filter[s]=a,-b",
+				lo: []LogicalOperator{
+					Or,
+				},
+			},
+			want: bson.M{
+				"$and": bson.A{
+					bson.M{"$or": bson.A{
+						bson.D{bson.E{Key: "n", Value: bson.D{bson.E{Key: "$gt", Value: int32(1)}}}},
+						bson.D{bson.E{Key: "n", Value: bson.D{bson.E{Key: "$lt", Value: int32(3)}}}},
+					}},
+					bson.M{"$or": bson.A{
+						bson.D{bson.E{Key: "s", Value: bson.D{bson.E{Key: "$ne", Value: "b"}}}},
+						bson.D{bson.E{Key: "s", Value: bson.D{bson.E{Key: "$in", Value: bson.A{"a"}}}}},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should error for a raw regex with nested quantifiers",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+				},
+				opts: QueryBuilderOptions().SetRawRegex(16),
+			},
+			args: args{
+				qs: `filter[sVal1]=/(a%2B)%2B$/`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "should allow a raw regex with quantifiers that are not nested",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"sVal1": "string",
+				},
+				opts: QueryBuilderOptions().SetRawRegex(16),
+			},
+			args: args{
+				qs: `filter[sVal1]=/^(ab)?c%2Bd*$/`,
+			},
+			want: bson.M{
+				"sVal1": primitive.Regex{Pattern: "^(ab)?c+d*$", Options: ""},
+			},
+		},
+		{
+			name: "should error for objectId values that only contain null",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"oid1": "objectId",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[oid1]=5fnull",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should allow null with multiple objectId values",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"oid1": "objectId",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[oid1]=null,5f1b7e3e9d9b4b0a8c8b4567",
+			},
+			want: bson.M{
+				"oid1": bson.D{bson.E{
+					Key: "$in",
+					Value: bson.A{
+						nil,
+						mustObjectID("5f1b7e3e9d9b4b0a8c8b4567"),
+					},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should include every increment within the second for epoch seconds",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"ts1": "timestamp",
+					"ts2": "timestamp",
+					"ts3": "timestamp",
+					"ts4": "timestamp",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[ts1]=%3C%3D1700000000&filter[ts2]=%3E1700000000&filter[ts3]=%3C1700000000&filter[ts4]=%3E%3D2023-11-14T22:13:20Z",
+			},
+			want: bson.M{
+				"ts1": bson.D{bson.E{Key: "$lte", Value: primitive.Timestamp{T: 1700000000, I: math.MaxUint32}}},
+				"ts2": bson.D{bson.E{Key: "$gt", Value: primitive.Timestamp{T: 1700000000, I: math.MaxUint32}}},
+				"ts3": bson.D{bson.E{Key: "$lt", Value: primitive.Timestamp{T: 1700000000}}},
+				"ts4": bson.D{bson.E{Key: "$gte", Value: primitive.Timestamp{T: 1700000000}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should error for timestamp dates outside of the uint32 range",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"ts1": "timestamp",
+				},
+				strictValidation: false,
+			},
+			args: args{
+				qs: "filter[ts1]=%3E1960-01-01",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should parse number fields as doubles",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"amount": "number",
+				},
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[amount]=%3E1.5&filter[amount][lt]=10",
+			},
+			want: bson.M{
+				"amount": bson.D{
+					bson.E{Key: "$gt", Value: float64(1.5)},
+					bson.E{Key: "$lt", Value: float64(10)},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should ignore filters for types that can not be filtered",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"pattern": "regex",
+				},
+				strictValidation: true,
+			},
+			args: args{
+				qs: "filter[pattern]=abc",
+			},
+			want:    bson.M{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := QueryBuilder{
+				collection:       tt.fields.collection,
+				fieldTypes:       tt.fields.fieldTypes,
+				opts:             tt.fields.opts,
+				strictValidation: tt.fields.strictValidation,
+			}
+
+			qo, err := queryoptions.FromQuerystring(tt.args.qs)
+			if err != nil {
+				t.Errorf("options.FromQuerystring() error = %v", err)
+				return
+			}
+
+			got, err := qb.Filter(qo, tt.args.lo...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			// check to see if it matches expectations
+			if !reflect.DeepEqual(got, tt.want) {
+				// values do not match
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
+			}
+
+			// ensure the filter is a structurally valid query document
+			if got != nil {
+				if err := validateQuery(got, true); err != nil {
+					t.Errorf("QueryBuilder.Filter() is not a valid query document: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_FindOptions(t *testing.T) {
+	var el int64 = 100
+
+	type fields struct {
+		collection       string
+		fieldTypes       map[string]string
+		opts             *queryBuilderOptions
+		strictValidation bool
+	}
+	type args struct {
+		qo queryoptions.Options
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    *options.FindOptions
+		wantErr bool
+	}{
+		{
+			name: "should properly determine Limit options with query options defined limit",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Page: map[string]int{
+						"limit": 100,
+					},
+				},
+			},
+			want: &options.FindOptions{
+				Limit: &el,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly determine Limit options with query options defined size",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Page: map[string]int{
+						"size": 100,
+					},
+				},
+			},
+			want: &options.FindOptions{
+				Limit: &el,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly determine Skip options with query options defined limit and offset",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Page: map[string]int{
+						"limit":  100,
+						"offset": 100,
+					},
+				},
+			},
+			want: &options.FindOptions{
+				Limit: &el,
+				Skip:  &el,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly determine Skip options with query options defined limit and skip",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Page: map[string]int{
+						"limit": 100,
+						"skip":  100,
+					},
+				},
+			},
+			want: &options.FindOptions{
+				Limit: &el,
+				Skip:  &el,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly determine Skip and Size options with query options defined page and size",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Page: map[string]int{
+						"page": 1,
+						"size": 100,
+					},
+				},
+			},
+			want: &options.FindOptions{
+				Limit: &el,
+				Skip:  &el,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly determine projection fields when provided",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				// notice use of + before fieldC to validate + prefix to field name
+				qo: queryoptions.Options{
+					Fields: []string{"fieldA", "fieldB", "+fieldC"},
+				},
+			},
+			want: &options.FindOptions{
+				Projection: map[string]int{
+					"fieldA": 1,
+					"fieldB": 1,
+					"fieldC": 1,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly determine excluded fields in projection when provided",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Fields: []string{"-fieldA"},
+				},
+			},
+			want: &options.FindOptions{
+				Projection: map[string]int{
+					"fieldA": 0,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly error when providing a field in projection that does not exist and strict validation is true",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: true,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Fields: []string{"-fieldA"},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should properly sort when sort details are provided",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				// notice the use of + and - as field prefixes below
+				qo: queryoptions.Options{
 					Sort: []string{"fieldA", "+fieldB", "-fieldC"},
 				},
 			},
-			want: &options.FindOptions{
-				Sort: map[string]int{
-					"fieldA": 1,
-					"fieldB": 1,
-					"fieldC": -1,
+			want: &options.FindOptions{
+				Sort: bson.D{
+					{Key: "fieldA", Value: 1},
+					{Key: "fieldB", Value: 1},
+					{Key: "fieldC", Value: -1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should properly error when providing a field in sort that does not exist and strict validation is true",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: true,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"-fieldA"},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should retain the requested order of sort keys",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"lastName", "-firstName", "age", "lastName"},
+				},
+			},
+			want: &options.FindOptions{
+				Sort: bson.D{
+					{Key: "lastName", Value: 1},
+					{Key: "firstName", Value: -1},
+					{Key: "age", Value: 1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should append the sort tiebreaker when configured",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				opts:             QueryBuilderOptions().SetSortTiebreaker("_id"),
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"lastName", "firstName"},
+				},
+			},
+			want: &options.FindOptions{
+				Sort: bson.D{
+					{Key: "lastName", Value: 1},
+					{Key: "firstName", Value: 1},
+					{Key: "_id", Value: 1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should not duplicate the sort tiebreaker when already requested",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				opts:             QueryBuilderOptions().SetSortTiebreaker("_id"),
+				strictValidation: false,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"-_id", "name"},
+				},
+			},
+			want: &options.FindOptions{
+				Sort: bson.D{
+					{Key: "_id", Value: -1},
+					{Key: "name", Value: 1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should sort and project the text score for a text search",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"name": "string",
+				},
+				strictValidation: true,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Fields: []string{"name"},
+					Filter: map[string][]string{"$search": {"coffee"}},
+					Sort:   []string{"score", "name"},
+				},
+			},
+			want: &options.FindOptions{
+				Projection: bson.M{
+					"name":  1,
+					"score": bson.D{{Key: "$meta", Value: "textScore"}},
+				},
+				Sort: bson.D{
+					{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}},
+					{Key: "name", Value: 1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should sort by a score field defined in the schema",
+			fields: fields{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"score": "int",
+				},
+				strictValidation: true,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"-score"},
+				},
+			},
+			want: &options.FindOptions{
+				Sort: bson.D{
+					{Key: "score", Value: -1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should error when sorting by an unknown score field without a text search",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: true,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"score"},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should sort by a score field without a text search",
+			fields: fields{
+				collection: "test",
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"-score"},
+				},
+			},
+			want: &options.FindOptions{
+				Sort: bson.D{
+					{Key: "score", Value: -1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should sort by a score field for an empty text search",
+			fields: fields{
+				collection: "test",
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Filter: map[string][]string{"$search": {" "}},
+					Sort:   []string{"score"},
+				},
+			},
+			want: &options.FindOptions{
+				Sort: bson.D{
+					{Key: "score", Value: 1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should ignore empty sort fields",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: true,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Sort: []string{"", "-", "+"},
+				},
+			},
+			want:    options.Find(),
+			wantErr: false,
+		},
+		{
+			name: "should ignore empty projection fields",
+			fields: fields{
+				collection:       "test",
+				fieldTypes:       map[string]string{},
+				strictValidation: true,
+			},
+			args: args{
+				qo: queryoptions.Options{
+					Fields: []string{"", "-", "+"},
+				},
+			},
+			want:    options.Find(),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := QueryBuilder{
+				collection:       tt.fields.collection,
+				fieldTypes:       tt.fields.fieldTypes,
+				opts:             tt.fields.opts,
+				strictValidation: tt.fields.strictValidation,
+			}
+			got, err := qb.FindOptions(tt.args.qo)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.FindOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryBuilder.FindOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustObjectID(hex string) primitive.ObjectID {
+	oid, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		panic(err)
+	}
+
+	return oid
+}
+
+func mustDecimal128(s string) primitive.Decimal128 {
+	d, err := primitive.ParseDecimal128(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+func TestQueryBuilder_ValidationErrors(t *testing.T) {
+	qb := QueryBuilder{
+		collection: "test",
+		fieldTypes: map[string]string{
+			"bVal": "bool",
+			"dVal": "date",
+			"iVal": "int",
+		},
+		strictValidation: true,
+	}
+
+	qo, err := queryoptions.FromQuerystring("filter[bVal]=%3Etrue&filter[dVal]=notadate&filter[iVal]=abc&filter[nofield]=value")
+	if err != nil {
+		t.Fatalf("options.FromQuerystring() error = %v", err)
+	}
+
+	_, err = qb.Filter(qo)
+	if err == nil {
+		t.Fatal("QueryBuilder.Filter() expected an error")
+	}
+
+	var ve ValidationErrors
+	if !errors.As(err, &ve) || len(ve) != 4 {
+		t.Fatalf("QueryBuilder.Filter() error = %v, want 4 ValidationErrors", err)
+	}
+
+	var ufe *UnknownFieldError
+	if !errors.As(err, &ufe) || ufe.Field != "nofield" || ufe.Collection != "test" || ufe.Value != "value" {
+		t.Errorf("QueryBuilder.Filter() UnknownFieldError = %+v", ufe)
+	}
+
+	var uoe *UnsupportedOperatorError
+	if !errors.As(err, &uoe) || uoe.Field != "bVal" || uoe.Operator != "$gt" || uoe.Collection != "test" {
+		t.Errorf("QueryBuilder.Filter() UnsupportedOperatorError = %+v", uoe)
+	}
+
+	// collect the invalid values that were reported
+	invalid := map[string]string{}
+	for _, e := range ve {
+		var ive *InvalidValueError
+		if errors.As(e, &ive) {
+			if ive.Collection != "test" || ive.Reason == "" {
+				t.Errorf("QueryBuilder.Filter() InvalidValueError = %+v", ive)
+			}
+			invalid[ive.Field] = ive.Value
+		}
+	}
+
+	want := map[string]string{"dVal": "notadate", "iVal": "abc"}
+	if !reflect.DeepEqual(invalid, want) {
+		t.Errorf("QueryBuilder.Filter() invalid values = %v, want %v", invalid, want)
+	}
+
+	// every unknown field in projection and sort is reported by FindOptions
+	_, err = qb.FindOptions(queryoptions.Options{
+		Fields: []string{"iVal", "noField1"},
+		Sort:   []string{"-noField2", "dVal"},
+	})
+	if !errors.As(err, &ve) || len(ve) != 2 {
+		t.Fatalf("QueryBuilder.FindOptions() error = %v, want 2 ValidationErrors", err)
+	}
+}
+
+func TestQueryBuilder_CountOptions(t *testing.T) {
+	qb := QueryBuilder{
+		collection: "test",
+		fieldTypes: map[string]string{
+			"iVal": "int",
+		},
+		strictValidation: true,
+	}
+
+	// sort, projection and pagination are ignored
+	got, err := qb.CountOptions(queryoptions.Options{
+		Fields: []string{"noField1"},
+		Filter: map[string][]string{"iVal": {">1"}},
+		Page:   map[string]int{"limit": 10, "offset": 10},
+		Sort:   []string{"noField2"},
+	})
+	if err != nil {
+		t.Fatalf("QueryBuilder.CountOptions() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, options.Count()) {
+		t.Errorf("QueryBuilder.CountOptions() = %v, want %v", got, options.Count())
+	}
+
+	// the filter is validated
+	if _, err := qb.CountOptions(queryoptions.Options{
+		Filter: map[string][]string{"iVal": {"abc"}, "noField": {"value"}},
+	}); err == nil {
+		t.Error("QueryBuilder.CountOptions() expected an error")
+	}
+}
+
+func TestQueryBuilder_Filter_Not(t *testing.T) {
+	qb := QueryBuilder{
+		collection: "test",
+		fieldTypes: map[string]string{
+			"bVal": "bool",
+			"dVal": "date",
+			"iVal": "int",
+			"sVal": "string",
+			"rVal": "string",
+			"aVal": "string",
+		},
+	}
+
+	qo, err := queryoptions.FromQuerystring("filter[bVal]=true&filter[dVal]=%3E2020-01-01T12:00:00.000Z&filter[iVal]=%3E%3D1,%3C5,3&filter[sVal]=value&filter[rVal]=val*&filter[aVal]=a,b")
+	if err != nil {
+		t.Fatalf("options.FromQuerystring() error = %v", err)
+	}
+
+	got, err := qb.Filter(qo, Not)
+	if err != nil {
+		t.Fatalf("QueryBuilder.Filter() error = %v", err)
+	}
+
+	want := bson.M{
+		"aVal": bson.D{bson.E{
+			Key:   "$nin",
+			Value: bson.A{"a", "b"},
+		}},
+		"bVal": bson.D{bson.E{
+			Key:   "$ne",
+			Value: true,
+		}},
+		"dVal": bson.D{bson.E{
+			Key: "$not",
+			Value: bson.D{bson.E{
+				Key:   "$gt",
+				Value: time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC),
+			}},
+		}},
+		"$nor": bson.A{
+			bson.D{bson.E{
+				Key: "iVal",
+				Value: bson.D{bson.E{
+					Key:   "$gte",
+					Value: int32(1),
+				}},
+			}},
+			bson.D{bson.E{
+				Key: "iVal",
+				Value: bson.D{bson.E{
+					Key:   "$lt",
+					Value: int32(5),
+				}},
+			}},
+			bson.D{bson.E{
+				Key: "iVal",
+				Value: bson.D{bson.E{
+					Key:   "$in",
+					Value: bson.A{int32(3)},
+				}},
+			}},
+		},
+		"rVal": bson.D{bson.E{
+			Key: "$not",
+			Value: primitive.Regex{
+				Pattern: "^val",
+				Options: "i",
+			},
+		}},
+		"sVal": bson.D{bson.E{
+			Key:   "$ne",
+			Value: "value",
+		}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, want)
+	}
+
+	if err := validateQuery(got, true); err != nil {
+		t.Errorf("QueryBuilder.Filter() is not a valid query document: %v", err)
+	}
+}
+
+// validateQuery ensures that a filter follows the structural rules of a MongoDB
+// query document: logical operators contain arrays of query documents and $not
+// is only used within a field expression with an operator expression or regex
+func validateQuery(doc any, topLevel bool) error {
+	var elems bson.D
+	switch d := doc.(type) {
+	case bson.M:
+		for k, v := range d {
+			elems = append(elems, bson.E{Key: k, Value: v})
+		}
+	case bson.D:
+		elems = d
+	default:
+		return fmt.Errorf("expected a query document, got %T", doc)
+	}
+
+	for _, e := range elems {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			a, ok := e.Value.(bson.A)
+			if !ok || len(a) == 0 {
+				return fmt.Errorf("%s requires a non-empty array", e.Key)
+			}
+
+			for _, sub := range a {
+				if err := validateQuery(sub, true); err != nil {
+					return err
+				}
+			}
+		case "$not":
+			return fmt.Errorf("$not is not valid as a top level operator")
+		default:
+			if !topLevel {
+				continue
+			}
+
+			// validate any $not within the field expression
+			if expr, ok := e.Value.(bson.D); ok {
+				for _, op := range expr {
+					if op.Key != "$not" {
+						continue
+					}
+
+					switch nv := op.Value.(type) {
+					case primitive.Regex:
+					case bson.D:
+						if len(nv) == 0 || nv[0].Key[0:1] != "$" {
+							return fmt.Errorf("$not requires an operator expression")
+						}
+					default:
+						return fmt.Errorf("$not requires an operator expression or regex, got %T", nv)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestQueryBuilder_Filter_Groups(t *testing.T) {
+	type args struct {
+		filter map[string][]string
+		o      []LogicalOperator
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			name: "should build an $or group with $and members",
+			args: args{
+				filter: map[string][]string{
+					"or][0][status":   {"open"},
+					"or][0][priority": {">3"},
+					"or][1][assignee": {"me"},
+				},
+			},
+			want: bson.M{
+				"$or": bson.A{
+					bson.M{
+						"priority": bson.D{bson.E{Key: "$gt", Value: int32(3)}},
+						"status":   "open",
+					},
+					bson.M{"assignee": "me"},
+				},
+			},
+		},
+		{
+			name: "should combine groups with other fields and support alternate key formats",
+			args: args{
+				filter: map[string][]string{
+					"status":           {"open"},
+					"or[0][assignee]":  {"me"},
+					"or[1][assignee]":  {"null"},
+					"nor][0][priority": {"<1"},
+				},
+			},
+			want: bson.M{
+				"status": "open",
+				"$nor": bson.A{
+					bson.M{"priority": bson.D{bson.E{Key: "$lt", Value: int32(1)}}},
+				},
+				"$or": bson.A{
+					bson.M{"assignee": "me"},
+					bson.M{"assignee": nil},
+				},
+			},
+		},
+		{
+			name: "should support nested groups",
+			args: args{
+				filter: map[string][]string{
+					"or][0][and][0][status":   {"open"},
+					"or][0][and][1][priority": {">=3"},
+					"or][1][assignee":         {"me"},
+				},
+			},
+			want: bson.M{
+				"$or": bson.A{
+					bson.M{"$and": bson.A{
+						bson.M{"status": "open"},
+						bson.M{"priority": bson.D{bson.E{Key: "$gte", Value: int32(3)}}},
+					}},
+					bson.M{"assignee": "me"},
+				},
+			},
+		},
+		{
+			name: "should not merge a group with an $or created for multiple values",
+			args: args{
+				filter: map[string][]string{
+					"priority":        {">5", "<1"},
+					"or][0][status":   {"open"},
+					"or][1][assignee": {"me"},
+				},
+				o: []LogicalOperator{Or},
+			},
+			want: bson.M{
+				"$and": bson.A{
+					bson.M{"$or": bson.A{
+						bson.D{bson.E{Key: "priority", Value: bson.D{bson.E{Key: "$gt", Value: int32(5)}}}},
+						bson.D{bson.E{Key: "priority", Value: bson.D{bson.E{Key: "$lt", Value: int32(1)}}}},
+					}},
+				},
+				"$or": bson.A{
+					bson.M{"status": "open"},
+					bson.M{"assignee": "me"},
+				},
+			},
+		},
+		{
+			name: "should report errors for fields within groups",
+			args: args{
+				filter: map[string][]string{
+					"or][0][priority": {"abc"},
+					"or][1][unknown":  {"me"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should negate the whole group with $nor for Not",
+			args: args{
+				filter: map[string][]string{
+					"or][0][priority": {"1"},
+					"or][1][priority": {"2"},
+					"status":          {"open"},
+				},
+				o: []LogicalOperator{Not},
+			},
+			want: bson.M{
+				"status": bson.D{bson.E{Key: "$ne", Value: "open"}},
+				"$nor": bson.A{
+					bson.M{"$or": bson.A{
+						bson.M{"priority": int32(1)},
+						bson.M{"priority": int32(2)},
+					}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := QueryBuilder{
+				collection: "test",
+				fieldTypes: map[string]string{
+					"assignee": "string",
+					"priority": "int",
+					"status":   "string",
+				},
+				strictValidation: true,
+			}
+
+			got, err := qb.Filter(queryoptions.Options{Filter: tt.args.filter}, tt.args.o...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				if ve, ok := err.(ValidationErrors); !ok || len(ve) != 2 {
+					t.Errorf("QueryBuilder.Filter() error = %v, want 2 ValidationErrors", err)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
+			}
+
+			if err := validateQuery(got, true); err != nil {
+				t.Errorf("QueryBuilder.Filter() is not a valid query document: %v", err)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Filter_Operators(t *testing.T) {
+	type args struct {
+		filter map[string][]string
+		o      []LogicalOperator
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			name: "should combine range operators for the same field",
+			args: args{
+				filter: map[string][]string{
+					"price][gte": {"10"},
+					"price][lt":  {"20"},
+				},
+			},
+			want: bson.M{
+				"price": bson.D{
+					bson.E{Key: "$gte", Value: int32(10)},
+					bson.E{Key: "$lt", Value: int32(20)},
+				},
+			},
+		},
+		{
+			name: "should parse negative numbers without treating the prefix as an operator",
+			args: args{
+				filter: map[string][]string{
+					"price][eq":       {"-5"},
+					"rating][ne":      {"-1.5"},
+					"created][lte":    {"2021-01-01"},
+					"active][eq":      {"true"},
+					"_id][in":         {"5f1b0d3e4f1a2b3c4d5e6f70", "5f1b0d3e4f1a2b3c4d5e6f71"},
+					"category][nin":   {"-a", "b"},
+					"deleted][exists": {"false"},
+				},
+			},
+			want: bson.M{
+				"price":  int32(-5),
+				"rating": bson.D{bson.E{Key: "$ne", Value: float64(-1.5)}},
+				"created": bson.D{bson.E{
+					Key:   "$lt",
+					Value: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+				}},
+				"active": true,
+				"_id": bson.D{bson.E{Key: "$in", Value: bson.A{
+					mustObjectID("5f1b0d3e4f1a2b3c4d5e6f70"),
+					mustObjectID("5f1b0d3e4f1a2b3c4d5e6f71"),
+				}}},
+				"category": bson.D{bson.E{Key: "$nin", Value: bson.A{"-a", "b"}}},
+				"deleted":  bson.D{bson.E{Key: "$exists", Value: false}},
+			},
+		},
+		{
+			name: "should use $in and $nin for multiple eq and ne values",
+			args: args{
+				filter: map[string][]string{
+					"price][eq":    {"1", "2"},
+					"category][ne": {"a", "null"},
+				},
+			},
+			want: bson.M{
+				"price":    bson.D{bson.E{Key: "$in", Value: bson.A{int32(1), int32(2)}}},
+				"category": bson.D{bson.E{Key: "$nin", Value: bson.A{"a", nil}}},
+			},
+		},
+		{
+			name: "should escape contains, startsWith and endsWith values",
+			args: args{
+				filter: map[string][]string{
+					"name][contains":       {"a.b"},
+					"category][startsWith": {"c+", "d"},
+					"tags][endswith":       {"(x)"},
+				},
+			},
+			want: bson.M{
+				"name": primitive.Regex{Pattern: `a\.b`, Options: "i"},
+				"category": bson.D{bson.E{Key: "$in", Value: bson.A{
+					primitive.Regex{Pattern: `^c\+`, Options: "i"},
+					primitive.Regex{Pattern: "^d", Options: "i"},
+				}}},
+				"tags": primitive.Regex{Pattern: `\(x\)$`, Options: "i"},
+			},
+		},
+		{
+			name: "should nest a repeated operator for the same field within $and",
+			args: args{
+				filter: map[string][]string{
+					"price":     {">5"},
+					"price][gt": {"10"},
+				},
+			},
+			want: bson.M{
+				"price": bson.D{bson.E{Key: "$gt", Value: int32(5)}},
+				"$and": bson.A{
+					bson.M{"price": bson.D{bson.E{Key: "$gt", Value: int32(10)}}},
+				},
+			},
+		},
+		{
+			name: "should negate named operators with Not",
+			args: args{
+				filter: map[string][]string{
+					"price][gte": {"10"},
+					"name][eq":   {"test"},
+				},
+				o: []LogicalOperator{Not},
+			},
+			want: bson.M{
+				"price": bson.D{bson.E{Key: "$not", Value: bson.D{bson.E{Key: "$gte", Value: int32(10)}}}},
+				"name":  bson.D{bson.E{Key: "$ne", Value: "test"}},
+			},
+		},
+		{
+			name: "should support named operators within groups",
+			args: args{
+				filter: map[string][]string{
+					"or][0][price][lt": {"-1"},
+					"or][1][price][gt": {"1"},
+				},
+			},
+			want: bson.M{
+				"$or": bson.A{
+					bson.M{"price": bson.D{bson.E{Key: "$lt", Value: int32(-1)}}},
+					bson.M{"price": bson.D{bson.E{Key: "$gt", Value: int32(1)}}},
 				},
 			},
-			wantErr: false,
 		},
 		{
-			name: "should properly error when providing a field in sort that does not exist and strict validation is true",
-			fields: fields{
-				collection:       "test",
-				fieldTypes:       map[string]string{},
-				strictValidation: true,
+			name: "should report invalid values and unsupported operators",
+			args: args{
+				filter: map[string][]string{
+					"active][gt":      {"true"},
+					"price][gte":      {"abc"},
+					"price][contains": {"1"},
+					"deleted][exists": {"maybe"},
+					"unknown][eq":     {"1"},
+				},
 			},
+			wantErr: true,
+		},
+		{
+			name: "should use $all, $size and exact for array fields",
 			args: args{
-				qo: queryoptions.Options{
-					Sort: []string{"-fieldA"},
+				filter: map[string][]string{
+					"labels][all":   {"red", "blue"},
+					"labels][size":  {"2"},
+					"scores][exact": {"1", "2"},
+				},
+			},
+			want: bson.M{
+				"labels": bson.D{
+					bson.E{Key: "$all", Value: bson.A{"red", "blue"}},
+					bson.E{Key: "$size", Value: int32(2)},
+				},
+				"scores": bson.A{int32(1), int32(2)},
+			},
+		},
+		{
+			name: "should use $in for any of multiple values for an array field",
+			args: args{
+				filter: map[string][]string{
+					"labels": {"red", "blue"},
+				},
+			},
+			want: bson.M{
+				"labels": bson.D{bson.E{Key: "$in", Value: bson.A{"red", "blue"}}},
+			},
+		},
+		{
+			name: "should match range operators to the same element of an array field",
+			args: args{
+				filter: map[string][]string{
+					"scores][gte": {"80"},
+					"scores][lt":  {"90"},
+				},
+			},
+			want: bson.M{
+				"scores": bson.D{bson.E{Key: "$elemMatch", Value: bson.D{
+					bson.E{Key: "$gte", Value: int32(80)},
+					bson.E{Key: "$lt", Value: int32(90)},
+				}}},
+			},
+		},
+		{
+			name: "should match a range literal to the same element of an array field",
+			args: args{
+				filter: map[string][]string{
+					"scores": {"80..90"},
+				},
+			},
+			want: bson.M{
+				"scores": bson.D{bson.E{Key: "$elemMatch", Value: bson.D{
+					bson.E{Key: "$gte", Value: int32(80)},
+					bson.E{Key: "$lte", Value: int32(90)},
+				}}},
+			},
+		},
+		{
+			name: "should match prefixed comparisons to the same element of an array field",
+			args: args{
+				filter: map[string][]string{
+					"scores": {">=80", "<90"},
+				},
+			},
+			want: bson.M{
+				"scores": bson.D{bson.E{Key: "$elemMatch", Value: bson.D{
+					bson.E{Key: "$gte", Value: int32(80)},
+					bson.E{Key: "$lt", Value: int32(90)},
+				}}},
+			},
+		},
+		{
+			name: "should error for array operators on fields that are not arrays",
+			args: args{
+				filter: map[string][]string{
+					"name][all":    {"a"},
+					"labels][size": {"-1"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should use $exists and $type for any field",
+			args: args{
+				filter: map[string][]string{
+					"name][exists":  {"false"},
+					"price][type":   {"number"},
+					"deleted][type": {"object", "null"},
+					"created][type": {"date"},
+				},
+			},
+			want: bson.M{
+				"name":    bson.D{bson.E{Key: "$exists", Value: false}},
+				"price":   bson.D{bson.E{Key: "$type", Value: "number"}},
+				"deleted": bson.D{bson.E{Key: "$type", Value: bson.A{"object", "null"}}},
+				"created": bson.D{bson.E{Key: "$type", Value: "date"}},
+			},
+		},
+		{
+			name: "should error for an unknown BSON type or a field not in the schema",
+			args: args{
+				filter: map[string][]string{
+					"price][type":   {"integer"},
+					"missing][type": {"string"},
 				},
 			},
-			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "should parse binData values for named operators",
+			args: args{
+				filter: map[string][]string{
+					"token][in": {"123e4567-e89b-12d3-a456-426614174000", "deadbeef"},
+				},
+			},
+			want: bson.M{
+				"token": bson.D{bson.E{Key: "$in", Value: bson.A{
+					primitive.Binary{
+						Subtype: bson.TypeBinaryUUID,
+						Data:    []byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+					},
+					primitive.Binary{Data: []byte{0xde, 0xad, 0xbe, 0xef}},
+				}}},
+			},
+		},
+		{
+			name: "should not lose a plain value combined with a named operator",
+			args: args{
+				filter: map[string][]string{
+					"price":        {"10"},
+					"price][gte":   {"5"},
+					"category":     {"open"},
+					"category][ne": {"closed"},
+					"rating":       {"1"},
+					"rating][eq":   {"2"},
+				},
+			},
+			want: bson.M{
+				"category": "open",
+				"price":    int32(10),
+				"rating":   float64(1),
+				"$and": bson.A{
+					bson.M{"category": bson.D{bson.E{Key: "$ne", Value: "closed"}}},
+					bson.M{"price": bson.D{bson.E{Key: "$gte", Value: int32(5)}}},
+					bson.M{"rating": float64(2)},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			qb := QueryBuilder{
-				collection:       tt.fields.collection,
-				fieldTypes:       tt.fields.fieldTypes,
-				strictValidation: tt.fields.strictValidation,
+				arrayFields: map[string]bool{
+					"labels": true,
+					"scores": true,
+				},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"_id":      "objectId",
+					"active":   "bool",
+					"category": "string",
+					"created":  "date",
+					"deleted":  "object",
+					"labels":   "string",
+					"name":     "string",
+					"price":    "int",
+					"rating":   "double",
+					"scores":   "int",
+					"tags":     "array",
+					"token":    "binData",
+				},
+				strictValidation: true,
 			}
-			got, err := qb.FindOptions(tt.args.qo)
 
+			got, err := qb.Filter(queryoptions.Options{Filter: tt.args.filter}, tt.args.o...)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("QueryBuilder.FindOptions() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+
+			if tt.wantErr {
+				if ve, ok := err.(ValidationErrors); !ok || len(ve) != len(tt.args.filter) {
+					t.Errorf("QueryBuilder.Filter() error = %v, want %d ValidationErrors", err, len(tt.args.filter))
+				}
+				return
+			}
+
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("QueryBuilder.FindOptions() = %v, want %v", got, tt.want)
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
+			}
+
+			if err := validateQuery(got, true); err != nil {
+				t.Errorf("QueryBuilder.Filter() is not a valid query document: %v", err)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Filter_SubDocuments(t *testing.T) {
+	type args struct {
+		filter map[string][]string
+		opts   *queryBuilderOptions
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			name: "should match fields within an array of sub-documents to the same element",
+			args: args{
+				filter: map[string][]string{
+					"authors.name":  {"Jane"},
+					"authors.email": {"*@example"},
+					"title":         {"Go"},
+				},
+			},
+			want: bson.M{
+				"authors": bson.D{bson.E{Key: "$elemMatch", Value: bson.M{
+					"email": primitive.Regex{Pattern: "@example$", Options: "i"},
+					"name":  "Jane",
+				}}},
+				"title": "Go",
+			},
+		},
+		{
+			name: "should not group a single field within an array of sub-documents",
+			args: args{
+				filter: map[string][]string{
+					"authors.name": {"Jane"},
+				},
+			},
+			want: bson.M{
+				"authors.name": "Jane",
+			},
+		},
+		{
+			name: "should group multiple values and negated values for a field",
+			args: args{
+				filter: map[string][]string{
+					"authors.age":  {">=30", "<40"},
+					"authors.name": {"-Jane", "-John"},
+				},
+			},
+			want: bson.M{
+				"authors": bson.D{bson.E{Key: "$elemMatch", Value: bson.M{
+					"$and": bson.A{
+						bson.D{bson.E{Key: "age", Value: bson.D{bson.E{Key: "$gte", Value: int32(30)}}}},
+						bson.D{bson.E{Key: "age", Value: bson.D{bson.E{Key: "$lt", Value: int32(40)}}}},
+					},
+					"name": bson.D{bson.E{Key: "$nin", Value: bson.A{"Jane", "John"}}},
+				}}},
+			},
+		},
+		{
+			name: "should nest $elemMatch for arrays of sub-documents within arrays",
+			args: args{
+				filter: map[string][]string{
+					"authors.name":        {"Jane"},
+					"authors.books.title": {"Go"},
+					"authors.books.year":  {"2024"},
+				},
+			},
+			want: bson.M{
+				"authors": bson.D{bson.E{Key: "$elemMatch", Value: bson.M{
+					"books": bson.D{bson.E{Key: "$elemMatch", Value: bson.M{
+						"title": "Go",
+						"year":  int32(2024),
+					}}},
+					"name": "Jane",
+				}}},
+			},
+		},
+		{
+			name: "should match fields within a filter group to the same element",
+			args: args{
+				filter: map[string][]string{
+					"or][0][authors.name":  {"Jane"},
+					"or][0][authors.email": {"jane@example.com"},
+					"or][1][title":         {"Go"},
+				},
+			},
+			want: bson.M{
+				"$or": bson.A{
+					bson.M{"authors": bson.D{bson.E{Key: "$elemMatch", Value: bson.M{
+						"email": "jane@example.com",
+						"name":  "Jane",
+					}}}},
+					bson.M{"title": "Go"},
+				},
+			},
+		},
+		{
+			name: "should not group fields that are combined with $or",
+			args: args{
+				filter: map[string][]string{
+					"authors.name":  {"Jane"},
+					"authors.email": {"jane@example.com"},
+				},
+				opts: QueryBuilderOptions().SetFieldOperator(Or),
+			},
+			want: bson.M{
+				"$or": bson.A{
+					bson.M{"authors.email": "jane@example.com"},
+					bson.M{"authors.name": "Jane"},
+				},
+			},
+		},
+		{
+			name: "should match fields independently when disabled",
+			args: args{
+				filter: map[string][]string{
+					"authors.name":  {"Jane"},
+					"authors.email": {"jane@example.com"},
+				},
+				opts: QueryBuilderOptions().SetMatchSubDocuments(false),
+			},
+			want: bson.M{
+				"authors.email": "jane@example.com",
+				"authors.name":  "Jane",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := QueryBuilder{
+				arrayFields: map[string]bool{
+					"authors":       true,
+					"authors.books": true,
+				},
+				collection: "test",
+				fieldTypes: map[string]string{
+					"authors":             "object",
+					"authors.age":         "int",
+					"authors.books":       "object",
+					"authors.books.title": "string",
+					"authors.books.year":  "int",
+					"authors.email":       "string",
+					"authors.name":        "string",
+					"title":               "string",
+				},
+				strictValidation: true,
+			}
+
+			got, err := qb.WithOptions(tt.args.opts).Filter(queryoptions.Options{Filter: tt.args.filter})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
+			}
+
+			if err := validateQuery(got, true); err != nil {
+				t.Errorf("QueryBuilder.Filter() is not a valid query document: %v", err)
+			}
+		})
+	}
+}
+
+// the filter groups, named operators, geospatial operators and text search
+// options rely on the query options parser providing the key within the outer
+// filter[...] brackets (i.e. filter[or][0][status] as or][0][status), so each is
+// parsed from a querystring rather than built as a filter map
+func TestQueryBuilder_Filter_Querystring(t *testing.T) {
+	schema := bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"properties": bson.M{
+				"assignee": bson.M{"bsonType": "string"},
+				"priority": bson.M{"bsonType": "int"},
+				"status":   bson.M{"bsonType": "string"},
+				"location": geoSchema["$jsonSchema"].(bson.M)["properties"].(bson.M)["location"],
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		qs      string
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			name: "should parse filter groups",
+			qs:   "filter[or][0][status]=open&filter[or][0][priority]=%3E3&filter[or][1][assignee]=me",
+			want: bson.M{
+				"$or": bson.A{
+					bson.M{
+						"status":   "open",
+						"priority": bson.D{bson.E{Key: "$gt", Value: int32(3)}},
+					},
+					bson.M{"assignee": "me"},
+				},
+			},
+		},
+		{
+			name: "should parse nested filter groups",
+			qs:   "filter[and][0][or][0][status]=open&filter[and][0][or][1][status]=pending",
+			want: bson.M{
+				"$and": bson.A{
+					bson.M{"$or": bson.A{
+						bson.M{"status": "open"},
+						bson.M{"status": "pending"},
+					}},
+				},
+			},
+		},
+		{
+			name: "should parse named operators",
+			qs:   "filter[priority][gte]=-1&filter[status][in]=open,pending",
+			want: bson.M{
+				"priority": bson.D{bson.E{Key: "$gte", Value: int32(-1)}},
+				"status":   bson.D{bson.E{Key: "$in", Value: bson.A{"open", "pending"}}},
+			},
+		},
+		{
+			name: "should parse geospatial operators",
+			qs:   "filter[location][near]=-122.4,37.8,500",
+			want: bson.M{
+				"location": bson.D{bson.E{Key: "$nearSphere", Value: bson.D{
+					bson.E{Key: "$geometry", Value: bson.D{
+						bson.E{Key: "type", Value: "Point"},
+						bson.E{Key: "coordinates", Value: bson.A{-122.4, 37.8}},
+					}},
+					bson.E{Key: "$maxDistance", Value: float64(500)},
+				}}},
+			},
+		},
+		{
+			name: "should parse text search options",
+			qs:   "filter[$search]=coffee&filter[$search][language]=en",
+			want: bson.M{
+				"$text": bson.D{
+					bson.E{Key: "$search", Value: "coffee"},
+					bson.E{Key: "$language", Value: "en"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qo, err := queryoptions.FromQuerystring(tt.qs)
+			if err != nil {
+				t.Fatalf("options.FromQuerystring() error = %v", err)
+			}
+
+			got, err := NewQueryBuilder("test", schema, true).Filter(qo)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,589 @@
+package querybuilder
+
+import "time"
+
+type queryBuilderOptions struct {
+	binarySubtype       *byte
+	binarySubtypeFields map[string]byte
+	caseSensitive       *bool
+	caseSensitiveFields map[string]bool
+	clock               func() time.Time
+	cursor              *Cursor
+	cursorSecret        []byte
+	exclusiveRanges     *bool
+	fieldOperator       *LogicalOperator
+	location            *time.Location
+	matchSubDocuments   *bool
+	pipelineFacet       *bool
+	rawRegex            *int
+	sortTiebreaker      *string
+	textSearch          *string
+}
+
+// QueryBuilderOptions provides a set of options for the QueryBuilder.
+func QueryBuilderOptions() *queryBuilderOptions {
+	return &queryBuilderOptions{}
+}
+
+// SetBinarySubtype sets the subtype of the primitive.Binary values that are
+// built for binData fields in filters. When fields are provided, the subtype
+// applies only to those fields and takes precedence over the subtype for the
+// builder. By default, a UUID value (i.e. 123e4567-e89b-12d3-a456-426614174000)
+// is subtype 4 (bson.TypeBinaryUUID) and a hex or base64 value is subtype 0
+// (bson.TypeBinaryGeneric).
+//
+//	func example() {
+//		// legacy UUIDs are stored with subtype 3
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetBinarySubtype(bson.TypeBinaryUUIDOld, "legacyID"))
+//
+//		// ?filter[legacyID]=123e4567-e89b-12d3-a456-426614174000 results in:
+//		// bson.M{
+//		//   "legacyID": primitive.Binary{Subtype: 3, Data: []byte{0x12, 0x3e, ...}},
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetBinarySubtype(subtype byte, fields ...string) *queryBuilderOptions {
+	if len(fields) == 0 {
+		qbo.binarySubtype = &subtype
+		return qbo
+	}
+
+	if qbo.binarySubtypeFields == nil {
+		qbo.binarySubtypeFields = map[string]byte{}
+	}
+
+	for _, f := range fields {
+		qbo.binarySubtypeFields[f] = subtype
+	}
+
+	return qbo
+}
+
+// SetCaseSensitive determines if the regex patterns built for string filters
+// (i.e. *term*, term* or *term and the contains, startsWith and endsWith named
+// operators) are case sensitive. By default, patterns are case insensitive.
+// When fields are provided, the setting applies only to those fields and takes
+// precedence over the setting for the builder... an exact match (i.e. "term")
+// is always case sensitive.
+//
+//	func example() {
+//		// case insensitive except for the sku field
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetCaseSensitive(true, "sku"))
+//
+//		// ?filter[sku]=AB*&filter[name]=*term* results in a filter like:
+//		// bson.M{
+//		//   "name": primitive.Regex{Pattern: "term", Options: "i"},
+//		//   "sku":  primitive.Regex{Pattern: "^AB", Options: ""},
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetCaseSensitive(b bool, fields ...string) *queryBuilderOptions {
+	if len(fields) == 0 {
+		qbo.caseSensitive = &b
+		return qbo
+	}
+
+	if qbo.caseSensitiveFields == nil {
+		qbo.caseSensitiveFields = map[string]bool{}
+	}
+
+	for _, f := range fields {
+		qbo.caseSensitiveFields[f] = b
+	}
+
+	return qbo
+}
+
+// SetClock sets the function that provides the current time when resolving
+// relative date expressions (i.e. now-7d, today or startOfMonth) in filters. By
+// default, time.Now is used... a fixed clock is useful for testing.
+//
+//	func example() {
+//		now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetClock(func() time.Time { return now }))
+//
+//		// ?filter[created]=>now-7d results in a filter like:
+//		// bson.M{
+//		//   "created": bson.D{{"$gt", time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)}},
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetClock(clock func() time.Time) *queryBuilderOptions {
+	qbo.clock = clock
+	return qbo
+}
+
+// SetCursor instructs the builder to use keyset (cursor) pagination with the
+// provided page[after] and/or page[before] tokens. When set, Filter includes a
+// range clause that selects the documents beyond the cursor and FindOptions
+// ignores any skip (limit and size are still respected). When only a
+// page[before] token is provided, FindOptions reverses the sort so that the
+// documents nearest to the cursor are returned first... the results should be
+// reversed before being returned to the client.
+//
+//	func example(r *http.Request) {
+//		// remove the cursor tokens before parsing the remaining querystring
+//		qs, cur, err := CursorFromQuerystring(r.URL.RawQuery)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//
+//		opt, err := queryoptions.FromQuerystring(qs)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//
+//		// use the cursor for this request only
+//		qb := builder.WithOptions(QueryBuilderOptions().SetCursor(cur))
+//
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//
+//		fo, err := qb.FindOptions(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//
+//		// ...find the documents, then create the page[after] token for the
+//		// next page from the last document
+//		next, err := qb.CursorToken(opt, docs[len(docs)-1])
+//	}
+func (qbo *queryBuilderOptions) SetCursor(cur Cursor) *queryBuilderOptions {
+	qbo.cursor = &cur
+	return qbo
+}
+
+// SetCursorSecret sets the secret that is used to sign cursor tokens so that a
+// token that has been tampered with is rejected.
+func (qbo *queryBuilderOptions) SetCursorSecret(secret []byte) *queryBuilderOptions {
+	qbo.cursorSecret = secret
+	return qbo
+}
+
+// SetExclusiveRanges instructs the builder to exclude the bounds of range
+// literals (i.e. filter[price]=10..20) from numeric and date filters. By
+// default, ranges are inclusive and use $gte and $lte... when exclusive, $gt
+// and $lt are used instead.
+//
+//	func example() {
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetExclusiveRanges(true))
+//
+//		// ?filter[price]=10..20 results in a filter like:
+//		// bson.M{
+//		//   "price": bson.D{{"$gt", 10}, {"$lt", 20}},
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetExclusiveRanges(b bool) *queryBuilderOptions {
+	qbo.exclusiveRanges = &b
+	return qbo
+}
+
+// SetFieldOperator sets the logical operator that is used by Filter to combine
+// the clauses for different fields. By default, fields are combined with And.
+// Use Or to match documents where any of the fields match, or Nor to match
+// documents where none of the fields match (Not is treated as Nor because $not
+// is not valid at the top level of a filter).
+//
+//	func example() {
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetFieldOperator(Or))
+//
+//		// ?filter[name]=*term*&filter[description]=*term* results in a filter like:
+//		// bson.M{
+//		//   "$or": bson.A{
+//		//     bson.M{"description": primitive.Regex{Pattern: "term", Options: "i"}},
+//		//     bson.M{"name": primitive.Regex{Pattern: "term", Options: "i"}},
+//		//   },
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetFieldOperator(lo LogicalOperator) *queryBuilderOptions {
+	qbo.fieldOperator = &lo
+	return qbo
+}
+
+// SetLocation sets the time zone used to interpret dates in filters that do not
+// include a time zone (i.e. 2024-03-10 or 2024-03-10T08:00:00) and to resolve
+// relative dates (i.e. today). By default, UTC is used. A date with a precision
+// of a day, month or year matches the whole period within the time zone. Use
+// WithOptions to override the location for a single request.
+//
+//	func example(r *http.Request) {
+//		loc, err := time.LoadLocation(r.Header.Get("X-Time-Zone"))
+//		if err != nil {
+//			loc = time.UTC
+//		}
+//
+//		// ?filter[created]=2024-03-10 in America/Los_Angeles results in:
+//		// bson.M{
+//		//   "created": bson.D{
+//		//     {"$gte", time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)},
+//		//     {"$lt", time.Date(2024, 3, 11, 7, 0, 0, 0, time.UTC)},
+//		//   },
+//		// }
+//		filter, err := builder.WithOptions(QueryBuilderOptions().SetLocation(loc)).Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetLocation(loc *time.Location) *queryBuilderOptions {
+	qbo.location = loc
+	return qbo
+}
+
+// SetMatchSubDocuments determines if filters for fields within the same array of
+// sub-documents (i.e. authors.name and authors.email) are grouped with
+// $elemMatch so that every filter applies to the same element of the array. By
+// default, the filters are grouped... when false, each filter is applied
+// independently and may match a different element of the array.
+//
+//	func example() {
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetMatchSubDocuments(false))
+//
+//		// ?filter[authors.name]=Jane&filter[authors.role]=editor results in:
+//		// bson.M{
+//		//   "authors.name": "Jane",
+//		//   "authors.role": "editor",
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetMatchSubDocuments(b bool) *queryBuilderOptions {
+	qbo.matchSubDocuments = &b
+	return qbo
+}
+
+// SetPipelineFacet instructs the builder to wrap the pagination and projection
+// stages created by Pipeline in a $facet stage that returns one page of data
+// along with the total count of documents that match the filter.
+//
+//	func example() {
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetPipelineFacet(true))
+//
+//		// ?filter[name]=test&page[limit]=10 results in a pipeline like:
+//		// mongo.Pipeline{
+//		//   {{"$match", bson.M{"name": "test"}}},
+//		//   {{"$facet", bson.D{
+//		//     {"data", bson.A{bson.D{{"$limit", 10}}}},
+//		//     {"total", bson.A{bson.D{{"$count", "count"}}}},
+//		//   }}},
+//		// }
+//		pl, err := qb.Pipeline(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetPipelineFacet(b bool) *queryBuilderOptions {
+	qbo.pipelineFacet = &b
+	return qbo
+}
+
+// SetRawRegex enables raw regex values for string filters (i.e.
+// filter[name]=/^ab.*$/i) with a pattern of at most maxLength characters. By
+// default, raw regex values are disabled and the value is compared as a string.
+// The pattern must be valid RE2 syntax (which excludes backreferences and
+// lookarounds) and the options are limited to i, m, s and x... a pattern that
+// is too long or is not valid results in an *InvalidValueError. Because the
+// querystring parser splits values on commas, a pattern can not contain a comma.
+//
+//	func example() {
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetRawRegex(64))
+//
+//		// ?filter[name]=/^ab.*$/i results in a filter like:
+//		// bson.M{
+//		//   "name": primitive.Regex{Pattern: "^ab.*$", Options: "i"},
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetRawRegex(maxLength int) *queryBuilderOptions {
+	qbo.rawRegex = &maxLength
+	return qbo
+}
+
+// SetSortTiebreaker instructs the builder to append the provided field (which
+// should be unique, such as _id) as the final key of the sort in FindOptions. This
+// ensures documents that share the same values for the requested sort keys are
+// always returned in the same order so that pagination remains stable.
+//
+//	func example() {
+//		// create a query builder that always sorts by _id last
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetSortTiebreaker("_id"))
+//
+//		// ?sort=lastName,firstName results in a sort option that looks like:
+//		// bson.D{
+//		//   {"lastName", 1},
+//		//   {"firstName", 1},
+//		//   {"_id", 1},
+//		// }
+//		fo, err := qb.FindOptions(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetSortTiebreaker(fld string) *queryBuilderOptions {
+	qbo.sortTiebreaker = &fld
+	return qbo
+}
+
+// SetTextSearchKey sets the filter key that is used for a full-text search
+// with the text index of the collection. By default, the key is $search (i.e.
+// filter[$search]=term)... a field in the schema with the same name as the key
+// can no longer be filtered, so choose a key that is not a field.
+//
+//	func example() {
+//		qb := NewQueryBuilder("collection", schema).WithOptions(
+//			QueryBuilderOptions().SetTextSearchKey("q"))
+//
+//		// ?filter[q]=coffee&filter[q][language]=en&sort=score results in a
+//		// filter like:
+//		// bson.M{
+//		//   "$text": bson.D{{"$search", "coffee"}, {"$language", "en"}},
+//		// }
+//		filter, err := qb.Filter(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//
+//		// ...and find options that sort and project the relevance score:
+//		// sort:       bson.D{{"score", bson.D{{"$meta", "textScore"}}}}
+//		// projection: bson.M{"score": bson.D{{"$meta", "textScore"}}}
+//		fo, err := qb.FindOptions(opt)
+//		if err != nil {
+//			fmt.Println(err)
+//			return
+//		}
+//	}
+func (qbo *queryBuilderOptions) SetTextSearchKey(key string) *queryBuilderOptions {
+	qbo.textSearch = &key
+	return qbo
+}
+
+func (qbo *queryBuilderOptions) subtype(field string) *byte {
+	if qbo == nil {
+		return nil
+	}
+
+	if st, ok := qbo.binarySubtypeFields[field]; ok {
+		return &st
+	}
+
+	return qbo.binarySubtype
+}
+
+func (qbo *queryBuilderOptions) now() time.Time {
+	if qbo == nil || qbo.clock == nil {
+		return time.Now()
+	}
+
+	return qbo.clock()
+}
+
+func (qbo *queryBuilderOptions) cursorTokens() *Cursor {
+	if qbo == nil || qbo.cursor == nil {
+		return nil
+	}
+
+	// an empty cursor does not enable keyset pagination
+	if qbo.cursor.After == "" && qbo.cursor.Before == "" {
+		return nil
+	}
+
+	return qbo.cursor
+}
+
+func (qbo *queryBuilderOptions) exclusive() bool {
+	return qbo != nil && qbo.exclusiveRanges != nil && *qbo.exclusiveRanges
+}
+
+func (qbo *queryBuilderOptions) fieldLogicalOperator() LogicalOperator {
+	if qbo == nil || qbo.fieldOperator == nil {
+		return And
+	}
+
+	return *qbo.fieldOperator
+}
+
+func (qbo *queryBuilderOptions) facet() bool {
+	return qbo != nil && qbo.pipelineFacet != nil && *qbo.pipelineFacet
+}
+
+func (qbo *queryBuilderOptions) subDocumentMatch() bool {
+	return qbo == nil || qbo.matchSubDocuments == nil || *qbo.matchSubDocuments
+}
+
+func (qbo *queryBuilderOptions) timeLocation() *time.Location {
+	if qbo == nil || qbo.location == nil {
+		return time.UTC
+	}
+
+	return qbo.location
+}
+
+func (qbo *queryBuilderOptions) rawRegexLength() int {
+	if qbo == nil || qbo.rawRegex == nil {
+		return 0
+	}
+
+	return *qbo.rawRegex
+}
+
+func (qbo *queryBuilderOptions) regexOptions(field string) string {
+	if qbo == nil {
+		return "i"
+	}
+
+	if cs, ok := qbo.caseSensitiveFields[field]; ok {
+		if cs {
+			return ""
+		}
+
+		return "i"
+	}
+
+	if qbo.caseSensitive != nil && *qbo.caseSensitive {
+		return ""
+	}
+
+	return "i"
+}
+
+func (qbo *queryBuilderOptions) secret() []byte {
+	if qbo == nil {
+		return nil
+	}
+
+	return qbo.cursorSecret
+}
+
+func (qbo *queryBuilderOptions) textSearchKey() string {
+	if qbo == nil || qbo.textSearch == nil || *qbo.textSearch == "" {
+		return defaultTextSearchKey
+	}
+
+	return *qbo.textSearch
+}
+
+func (qbo *queryBuilderOptions) tiebreaker() string {
+	if qbo == nil || qbo.sortTiebreaker == nil {
+		return ""
+	}
+
+	return *qbo.sortTiebreaker
+}
+
+func mergeQueryBuilderOptions(opts ...*queryBuilderOptions) *queryBuilderOptions {
+	qbo := QueryBuilderOptions()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+
+		if opt.binarySubtype != nil {
+			qbo.SetBinarySubtype(*opt.binarySubtype)
+		}
+
+		for f, st := range opt.binarySubtypeFields {
+			qbo.SetBinarySubtype(st, f)
+		}
+
+		if opt.caseSensitive != nil {
+			qbo.SetCaseSensitive(*opt.caseSensitive)
+		}
+
+		for f, cs := range opt.caseSensitiveFields {
+			qbo.SetCaseSensitive(cs, f)
+		}
+
+		if opt.clock != nil {
+			qbo.SetClock(opt.clock)
+		}
+
+		if opt.cursor != nil {
+			qbo.SetCursor(*opt.cursor)
+		}
+
+		if opt.cursorSecret != nil {
+			qbo.SetCursorSecret(opt.cursorSecret)
+		}
+
+		if opt.exclusiveRanges != nil {
+			qbo.SetExclusiveRanges(*opt.exclusiveRanges)
+		}
+
+		if opt.fieldOperator != nil {
+			qbo.SetFieldOperator(*opt.fieldOperator)
+		}
+
+		if opt.location != nil {
+			qbo.SetLocation(opt.location)
+		}
+
+		if opt.matchSubDocuments != nil {
+			qbo.SetMatchSubDocuments(*opt.matchSubDocuments)
+		}
+
+		if opt.pipelineFacet != nil {
+			qbo.SetPipelineFacet(*opt.pipelineFacet)
+		}
+
+		if opt.rawRegex != nil {
+			qbo.SetRawRegex(*opt.rawRegex)
+		}
+
+		if opt.sortTiebreaker != nil {
+			qbo.SetSortTiebreaker(*opt.sortTiebreaker)
+		}
+
+		if opt.textSearch != nil {
+			qbo.SetTextSearchKey(*opt.textSearch)
+		}
+	}
+
+	return qbo
+}
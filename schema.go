@@ -0,0 +1,366 @@
+package querybuilder
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// schemaDefinitions are the shared definitions of a schema (within definitions
+// or $defs) keyed by the local reference used with $ref (i.e.
+// #/definitions/address)
+type schemaDefinitions map[string]bson.M
+
+func newSchemaDefinitions(root bson.M) schemaDefinitions {
+	defs := schemaDefinitions{}
+	for _, k := range []string{"definitions", "$defs"} {
+		d, ok := root[k].(bson.M)
+		if !ok {
+			continue
+		}
+
+		for name, s := range d {
+			if sm, ok := s.(bson.M); ok {
+				defs[fmt.Sprintf("#/%s/%s", k, name)] = sm
+			}
+		}
+	}
+
+	return defs
+}
+
+// flatten resolves any local $ref and combines the alternatives of allOf, anyOf
+// and oneOf into a single schema... the bsonType of the result is every type
+// allowed by the schema and the alternatives, and the properties (and items)
+// of the alternatives are combined with anyOf. Each reference that is resolved
+// is added to refs and a reference that is already in refs is not resolved
+// again so that recursive definitions are not followed indefinitely.
+func (defs schemaDefinitions) flatten(s bson.M, refs map[string]bool) bson.M {
+	types := bson.A{}
+	props := []bson.M{}
+	items := []bson.M{}
+	flat := bson.M{}
+
+	add := func(alt bson.M) {
+		for _, t := range schemaTypes(alt["bsonType"]) {
+			if !containsValue(types, t) {
+				types = append(types, t)
+			}
+		}
+
+		if p, ok := alt["properties"].(bson.M); ok {
+			props = append(props, p)
+		}
+
+		if it, ok := alt["items"].(bson.M); ok {
+			items = append(items, it)
+		}
+
+		if e, ok := alt["enum"]; ok {
+			flat["enum"] = e
+		}
+	}
+
+	add(s)
+
+	if ref, ok := s["$ref"].(string); ok && !refs[ref] {
+		if d, ok := defs[ref]; ok {
+			refs[ref] = true
+			add(defs.flatten(d, refs))
+		}
+	}
+
+	for _, k := range []string{"allOf", "anyOf", "oneOf"} {
+		alts, ok := s[k].(bson.A)
+		if !ok {
+			continue
+		}
+
+		for _, alt := range alts {
+			if am, ok := alt.(bson.M); ok {
+				add(defs.flatten(am, refs))
+			}
+		}
+	}
+
+	if len(types) > 0 {
+		flat["bsonType"] = types
+	}
+
+	if len(props) > 0 {
+		flat["properties"] = mergeProperties(props...)
+	}
+
+	switch len(items) {
+	case 0:
+	case 1:
+		flat["items"] = items[0]
+	default:
+		alts := bson.A{}
+		for _, it := range items {
+			alts = append(alts, it)
+		}
+
+		flat["items"] = bson.M{"anyOf": alts}
+	}
+
+	return flat
+}
+
+// mergeProperties combines the properties of several schemas... a property that
+// is defined by more than one schema is combined with anyOf
+func mergeProperties(props ...bson.M) bson.M {
+	if len(props) == 1 {
+		return props[0]
+	}
+
+	merged := bson.M{}
+	for _, p := range props {
+		for field, s := range p {
+			existing, ok := merged[field]
+			if !ok {
+				merged[field] = s
+				continue
+			}
+
+			if em, ok := existing.(bson.M); ok && len(em) == 1 {
+				if alts, ok := em["anyOf"].(bson.A); ok {
+					em["anyOf"] = append(alts, s)
+					continue
+				}
+			}
+
+			merged[field] = bson.M{"anyOf": bson.A{existing, s}}
+		}
+	}
+
+	return merged
+}
+
+// normalizeSchema converts the documents and arrays within a schema (i.e.
+// map[string]any, bson.D, []any and []string) to bson.M and bson.A
+func normalizeSchema(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := bson.M{}
+		for k, e := range v {
+			m[k] = normalizeSchema(e)
+		}
+
+		return m
+	case bson.M:
+		m := bson.M{}
+		for k, e := range v {
+			m[k] = normalizeSchema(e)
+		}
+
+		return m
+	case bson.D:
+		m := bson.M{}
+		for _, e := range v {
+			m[e.Key] = normalizeSchema(e.Value)
+		}
+
+		return m
+	case []any:
+		return normalizeSchema(bson.A(v))
+	case bson.A:
+		a := bson.A{}
+		for _, e := range v {
+			a = append(a, normalizeSchema(e))
+		}
+
+		return a
+	case []string:
+		a := bson.A{}
+		for _, e := range v {
+			a = append(a, e)
+		}
+
+		return a
+	case []map[string]any:
+		a := bson.A{}
+		for _, e := range v {
+			a = append(a, normalizeSchema(e))
+		}
+
+		return a
+	case []bson.M:
+		a := bson.A{}
+		for _, e := range v {
+			a = append(a, normalizeSchema(e))
+		}
+
+		return a
+	}
+
+	return v
+}
+
+// schemaTypes returns the types of a bsonType, which may be a single type (i.e.
+// "string") or a list of types (i.e. ["string", "null"])
+func schemaTypes(v any) []string {
+	types := []string{}
+
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			types = append(types, v)
+		}
+	case bson.A:
+		for _, t := range v {
+			if ts, ok := t.(string); ok && ts != "" {
+				types = append(types, ts)
+			}
+		}
+	}
+
+	return types
+}
+
+// containsValue determines if the array contains the value
+func containsValue(a bson.A, v any) bool {
+	for _, e := range a {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateSchema reports every problem within a schema that prevents the fields
+// from being parsed along with the JSON path of the problem (i.e.
+// $.properties.name.bsonType)
+func validateSchema(schema any) error {
+	m, err := decodeSchema(schema)
+	if err != nil {
+		return ValidationErrors{&SchemaError{Path: "$", Reason: err.Error()}}
+	}
+
+	path := "$"
+	if js, ok := m["$jsonSchema"]; ok {
+		path = "$.$jsonSchema"
+
+		jm, ok := js.(bson.M)
+		if !ok {
+			return ValidationErrors{&SchemaError{Path: path, Reason: "expected a document"}}
+		}
+
+		m = jm
+	}
+
+	errs := ValidationErrors{}
+	newSchemaDefinitions(m).validate(path, m, &errs)
+
+	return errs.err()
+}
+
+// validate checks the keywords of a schema (and any nested schemas) that are
+// used when parsing the fields
+func (defs schemaDefinitions) validate(path string, s bson.M, errs *ValidationErrors) {
+	invalid := func(p string, reason string, args ...any) {
+		errs.add(&SchemaError{Path: p, Reason: fmt.Sprintf(reason, args...)})
+	}
+
+	// validate a keyword that contains a single schema
+	schema := func(p string, v any) {
+		sm, ok := v.(bson.M)
+		if !ok {
+			invalid(p, "expected a document, got %T", v)
+			return
+		}
+
+		defs.validate(p, sm, errs)
+	}
+
+	// validate a keyword that contains a document of schemas
+	schemas := func(p string, v any) {
+		m, ok := v.(bson.M)
+		if !ok {
+			invalid(p, "expected a document, got %T", v)
+			return
+		}
+
+		for _, k := range sortedKeys(m) {
+			schema(fmt.Sprintf("%s.%s", p, k), m[k])
+		}
+	}
+
+	// validate a keyword that contains an array of schemas
+	list := func(p string, v any) {
+		a, ok := v.(bson.A)
+		if !ok {
+			invalid(p, "expected an array, got %T", v)
+			return
+		}
+
+		for i, e := range a {
+			schema(fmt.Sprintf("%s[%d]", p, i), e)
+		}
+	}
+
+	if v, ok := s["bsonType"]; ok {
+		p := fmt.Sprintf("%s.bsonType", path)
+
+		switch bt := v.(type) {
+		case string:
+			if !bsonTypes[bt] {
+				invalid(p, "unknown bsonType %q", bt)
+			}
+		case bson.A:
+			for i, t := range bt {
+				if ts, ok := t.(string); !ok || !bsonTypes[ts] {
+					invalid(fmt.Sprintf("%s[%d]", p, i), "unknown bsonType %v", t)
+				}
+			}
+		default:
+			invalid(p, "expected a string or an array of strings, got %T", v)
+		}
+	}
+
+	if v, ok := s["properties"]; ok {
+		schemas(fmt.Sprintf("%s.properties", path), v)
+	}
+
+	if v, ok := s["items"]; ok {
+		p := fmt.Sprintf("%s.items", path)
+
+		// items may be a single schema or an array of schemas (a tuple)
+		if _, ok := v.(bson.A); ok {
+			list(p, v)
+		} else {
+			schema(p, v)
+		}
+	}
+
+	for _, k := range []string{"allOf", "anyOf", "oneOf"} {
+		if v, ok := s[k]; ok {
+			list(fmt.Sprintf("%s.%s", path, k), v)
+		}
+	}
+
+	for _, k := range []string{"definitions", "$defs"} {
+		if v, ok := s[k]; ok {
+			schemas(fmt.Sprintf("%s.%s", path, k), v)
+		}
+	}
+
+	if v, ok := s["$ref"]; ok {
+		p := fmt.Sprintf("%s.$ref", path)
+
+		ref, ok := v.(string)
+		switch {
+		case !ok:
+			invalid(p, "expected a string, got %T", v)
+		case defs[ref] == nil:
+			invalid(p, "unable to resolve %q... only local references to definitions or $defs are supported", ref)
+		}
+	}
+
+	if v, ok := s["enum"]; ok {
+		if _, ok := v.(bson.A); !ok {
+			invalid(fmt.Sprintf("%s.enum", path), "expected an array, got %T", v)
+		}
+	}
+}
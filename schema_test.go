@@ -0,0 +1,373 @@
+package querybuilder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	queryoptions "go.jtlabs.io/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var unionSchema = `{
+	"$jsonSchema": {
+		"bsonType": "object",
+		"definitions": {
+			"address": {
+				"bsonType": "object",
+				"properties": {
+					"city": { "bsonType": "string" },
+					"postcode": { "bsonType": ["int", "string"] }
+				}
+			},
+			"node": {
+				"bsonType": "object",
+				"properties": {
+					"name": { "bsonType": "string" },
+					"children": {
+						"bsonType": "array",
+						"items": { "$ref": "#/definitions/node" }
+					}
+				}
+			}
+		},
+		"properties": {
+			"code": { "bsonType": ["number", "string"] },
+			"meta": { "bsonType": ["object", "string"] },
+			"nickname": { "bsonType": ["string", "null"] },
+			"rank": { "bsonType": ["null", "int", "string"] },
+			"home": { "$ref": "#/definitions/address" },
+			"offices": {
+				"bsonType": "array",
+				"items": { "$ref": "#/definitions/address" }
+			},
+			"tree": { "$ref": "#/definitions/node" },
+			"contact": {
+				"oneOf": [
+					{
+						"bsonType": "object",
+						"properties": { "email": { "bsonType": "string" } }
+					},
+					{
+						"bsonType": "object",
+						"properties": { "phone": { "bsonType": "long" } }
+					}
+				]
+			},
+			"score": {
+				"anyOf": [
+					{ "bsonType": "double" },
+					{ "bsonType": "array", "items": { "bsonType": "double" } }
+				]
+			},
+			"audit": {
+				"allOf": [
+					{ "properties": { "created": { "bsonType": "date" } } },
+					{ "bsonType": "object", "properties": { "by": { "bsonType": "string" } } }
+				]
+			}
+		}
+	}
+}`
+
+func Test_parseSchemaFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     any
+		wantTypes  map[string]string
+		wantUnions map[string][]string
+		wantArrays map[string]bool
+	}{
+		{
+			name:   "should parse union types, combinators and references",
+			schema: unionSchema,
+			wantTypes: map[string]string{
+				"audit":            "object",
+				"audit.by":         "string",
+				"audit.created":    "date",
+				"code":             "number",
+				"contact":          "object",
+				"contact.email":    "string",
+				"contact.phone":    "long",
+				"home":             "object",
+				"home.city":        "string",
+				"home.postcode":    "int",
+				"meta":             "object",
+				"nickname":         "string",
+				"offices":          "object",
+				"offices.city":     "string",
+				"offices.postcode": "int",
+				"rank":             "int",
+				"score":            "double",
+				"tree":             "object",
+				"tree.children":    "array",
+				"tree.name":        "string",
+			},
+			wantUnions: map[string][]string{
+				"code":             {"number", "string"},
+				"home.postcode":    {"int", "string"},
+				"meta":             {"object", "string"},
+				"offices.postcode": {"int", "string"},
+				"rank":             {"int", "string"},
+			},
+			wantArrays: map[string]bool{
+				"offices":       true,
+				"score":         true,
+				"tree.children": true,
+			},
+		},
+		{
+			name: "should parse a list of types in a map schema",
+			schema: map[string]any{
+				"properties": map[string]any{
+					"tags": map[string]any{
+						"bsonType": []string{"array", "null"},
+						"items":    map[string]any{"bsonType": "string"},
+					},
+					"count": map[string]any{
+						"bsonType": []any{"int", "long"},
+					},
+				},
+			},
+			wantTypes: map[string]string{
+				"count": "int",
+				"tags":  "string",
+			},
+			wantUnions: map[string][]string{
+				"count": {"int", "long"},
+			},
+			wantArrays: map[string]bool{
+				"tags": true,
+			},
+		},
+		{
+			name: "should parse a list of types in a bson schema",
+			schema: bson.M{
+				"$jsonSchema": bson.M{
+					"properties": bson.M{
+						"value": bson.D{
+							{Key: "bsonType", Value: bson.A{"date", "string", "null"}},
+						},
+					},
+				},
+			},
+			wantTypes: map[string]string{
+				"value": "date",
+			},
+			wantUnions: map[string][]string{
+				"value": {"date", "string"},
+			},
+			wantArrays: map[string]bool{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSchemaFields(tt.schema)
+
+			if !reflect.DeepEqual(got.types, tt.wantTypes) {
+				t.Errorf("parseSchemaFields() types = %v, want %v", got.types, tt.wantTypes)
+			}
+
+			if !reflect.DeepEqual(got.unions, tt.wantUnions) {
+				t.Errorf("parseSchemaFields() unions = %v, want %v", got.unions, tt.wantUnions)
+			}
+
+			if !reflect.DeepEqual(got.arrays, tt.wantArrays) {
+				t.Errorf("parseSchemaFields() arrays = %v, want %v", got.arrays, tt.wantArrays)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Filter_UnionTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  map[string][]string
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			name: "should use the first type that is able to parse the value",
+			filter: map[string][]string{
+				"rank":          {">5"},
+				"home.postcode": {"SW1A"},
+				"nickname":      {"null"},
+			},
+			want: bson.M{
+				"rank":          bson.D{bson.E{Key: "$gt", Value: int32(5)}},
+				"home.postcode": "SW1A",
+				"nickname":      nil,
+			},
+		},
+		{
+			name: "should try each type for named operators",
+			filter: map[string][]string{
+				"rank][in": {"first", "second"},
+			},
+			want: bson.M{
+				"rank": bson.D{bson.E{Key: "$in", Value: bson.A{"first", "second"}}},
+			},
+		},
+		{
+			name: "should filter fields defined by references",
+			filter: map[string][]string{
+				"tree.name":     {"root"},
+				"audit.created": {">2024-01-01T00:00:00Z"},
+			},
+			want: bson.M{
+				"tree.name":     "root",
+				"audit.created": bson.D{bson.E{Key: "$gt", Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			},
+		},
+		{
+			name: "should parse a number type as a double",
+			filter: map[string][]string{
+				"code": {"5"},
+			},
+			want: bson.M{
+				"code": float64(5),
+			},
+		},
+		{
+			name: "should try the next type when a value is not a number",
+			filter: map[string][]string{
+				"code": {"abc"},
+			},
+			want: bson.M{
+				"code": "abc",
+			},
+		},
+		{
+			name: "should compare a value as a string before an object",
+			filter: map[string][]string{
+				"meta": {"abc"},
+			},
+			want: bson.M{
+				"meta": "abc",
+			},
+		},
+		{
+			name: "should error when no type is able to parse the value",
+			filter: map[string][]string{
+				"contact.phone": {"abc"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder("test", unionSchema, true)
+
+			got, err := qb.Filter(queryoptions.Options{Filter: tt.filter})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QueryBuilder.Filter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryBuilder.Filter() = \n%v\n, want \n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_NewQueryBuilderE(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    any
+		wantPaths []string
+	}{
+		{
+			name:   "should accept a valid schema string",
+			schema: testSchema,
+		},
+		{
+			name:   "should accept a valid schema with definitions",
+			schema: []byte(unionSchema),
+		},
+		{
+			name:   "should accept a nil schema",
+			schema: nil,
+		},
+		{
+			name:      "should report malformed JSON",
+			schema:    `{"$jsonSchema": {`,
+			wantPaths: []string{"$"},
+		},
+		{
+			name:      "should report an unsupported schema type",
+			schema:    42,
+			wantPaths: []string{"$"},
+		},
+		{
+			name:      "should report a $jsonSchema that is not a document",
+			schema:    bson.M{"$jsonSchema": "object"},
+			wantPaths: []string{"$.$jsonSchema"},
+		},
+		{
+			name: "should report the path of each problem",
+			schema: map[string]any{
+				"properties": map[string]any{
+					"name":  map[string]any{"bsonType": "text"},
+					"rank":  map[string]any{"bsonType": []any{"int", 5}},
+					"tags":  map[string]any{"bsonType": "array", "items": "string"},
+					"owner": map[string]any{"$ref": "#/definitions/person"},
+					"state": "string",
+				},
+				"anyOf": []any{
+					map[string]any{"required": []any{"name"}},
+					"name",
+				},
+			},
+			wantPaths: []string{
+				"$.properties.name.bsonType",
+				"$.properties.owner.$ref",
+				"$.properties.rank.bsonType[1]",
+				"$.properties.state",
+				"$.properties.tags.items",
+				"$.anyOf[1]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb, err := NewQueryBuilderE("test", tt.schema)
+			if (err != nil) != (len(tt.wantPaths) > 0) {
+				t.Errorf("NewQueryBuilderE() error = %v, wantPaths %v", err, tt.wantPaths)
+				return
+			}
+
+			if err == nil {
+				if qb == nil {
+					t.Error("NewQueryBuilderE() returned a nil QueryBuilder")
+				}
+
+				return
+			}
+
+			var ve ValidationErrors
+			if !errors.As(err, &ve) {
+				t.Fatalf("NewQueryBuilderE() error = %T, want ValidationErrors", err)
+			}
+
+			paths := []string{}
+			for _, e := range ve {
+				se, ok := e.(*SchemaError)
+				if !ok {
+					t.Fatalf("NewQueryBuilderE() error = %T, want *SchemaError", e)
+				}
+
+				if se.Collection != "test" {
+					t.Errorf("SchemaError.Collection = %s, want test", se.Collection)
+				}
+
+				paths = append(paths, se.Path)
+			}
+
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("NewQueryBuilderE() paths = %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
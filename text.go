@@ -0,0 +1,155 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	options "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultTextSearchKey is the filter key used for full-text search (i.e.
+	// filter[$search]=term) unless a key is provided with SetTextSearchKey
+	defaultTextSearchKey = "$search"
+
+	// textScoreField is the sort field (i.e. sort=score) and the projected
+	// field for the relevance of each document to a full-text search
+	textScoreField = "score"
+)
+
+// textSearchOptions maps the options that can be provided in brackets following
+// the text search key (i.e. filter[$search][language]=es) to the $text option
+var textSearchOptions = map[string]string{
+	"casesensitive":      "$caseSensitive",
+	"diacriticsensitive": "$diacriticSensitive",
+	"language":           "$language",
+}
+
+// textScore is the expression for the relevance score of a full-text search
+func textScore() bson.D {
+	return bson.D{bson.E{Key: "$meta", Value: "textScore"}}
+}
+
+// isTextSearch determines if the filter key is the text search key or one of
+// the options for the text search (i.e. $search][language)
+func (qb QueryBuilder) isTextSearch(key string) bool {
+	tk := qb.opts.textSearchKey()
+	return key == tk || strings.HasPrefix(key, fmt.Sprintf("%s][", tk))
+}
+
+// isTextScore determines if a sort field refers to the relevance score of a
+// full-text search... a field named score in the schema takes precedence and,
+// without a text search in the filter, score is sorted as any other field
+func (qb QueryBuilder) isTextScore(field string, filter map[string][]string) bool {
+	if field != textScoreField {
+		return false
+	}
+
+	if _, ok := qb.fieldTypes[field]; ok {
+		return false
+	}
+
+	return qb.hasTextSearch(filter)
+}
+
+// textFilter separates the text search (and any options) from the remaining
+// filters and builds the $text clause... multiple values for the search are
+// joined with a space so that each is a term of the search
+func (qb QueryBuilder) textFilter(filter map[string][]string) (map[string][]string, bson.M, error) {
+	errs := ValidationErrors{}
+	remaining := map[string][]string{}
+	search := []string{}
+	opts := bson.D{}
+
+	for _, key := range sortedKeys(filter) {
+		values := filter[key]
+
+		if !qb.isTextSearch(key) {
+			remaining[key] = values
+			continue
+		}
+
+		path := filterKeyPath(key)
+		if len(path) == 1 {
+			search = append(search, values...)
+			continue
+		}
+
+		oper, ok := textSearchOptions[strings.ToLower(filterKey(path[1:]))]
+		if !ok {
+			errs.add(&UnsupportedOperatorError{
+				Field:    key,
+				Value:    strings.Join(values, ","),
+				Operator: filterKey(path[1:]),
+				Reason:   "text search only supports the language, caseSensitive and diacriticSensitive options",
+			})
+			continue
+		}
+
+		if len(values) != 1 {
+			errs.add(invalidValue(key, strings.Join(values, ","), "", fmt.Errorf("expected a single value")))
+			continue
+		}
+
+		if oper == "$language" {
+			opts = append(opts, bson.E{Key: oper, Value: values[0]})
+			continue
+		}
+
+		b, err := strconv.ParseBool(values[0])
+		if err != nil {
+			errs.add(invalidValue(key, values[0], "", err))
+			continue
+		}
+
+		opts = append(opts, bson.E{Key: oper, Value: b})
+	}
+
+	term := strings.TrimSpace(strings.Join(search, " "))
+	if term == "" {
+		if len(opts) > 0 {
+			errs.add(invalidValue(qb.opts.textSearchKey(), "", "", fmt.Errorf("expected a search term with the text search options")))
+		}
+
+		return remaining, nil, errs.err()
+	}
+
+	text := append(bson.D{bson.E{Key: "$search", Value: term}}, opts...)
+
+	return remaining, bson.M{"$text": text}, errs.err()
+}
+
+// hasTextSearch determines if the filter includes a full-text search with a
+// term... an empty search (i.e. filter[$search]=) does not build a $text clause
+func (qb QueryBuilder) hasTextSearch(filter map[string][]string) bool {
+	search := filter[qb.opts.textSearchKey()]
+	return strings.TrimSpace(strings.Join(search, " ")) != ""
+}
+
+// hasTextScore determines if the sort includes the relevance score of a text
+// search
+func hasTextScore(sort bson.D) bool {
+	for _, e := range sort {
+		if _, ok := e.Value.(bson.D); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setTextScoreProjection projects the relevance score of a text search along
+// with any fields that have been requested
+func (qb QueryBuilder) setTextScoreProjection(opts *options.FindOptions) {
+	prj := bson.M{}
+	if fields, ok := opts.Projection.(map[string]int); ok {
+		for field, val := range fields {
+			prj[field] = val
+		}
+	}
+
+	prj[textScoreField] = textScore()
+	opts.SetProjection(prj)
+}
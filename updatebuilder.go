@@ -25,12 +25,27 @@ func NewUpdateBuilder(collection string, schema any, opts ...*updateOptions) *Up
 	return &ub
 }
 
+// NewUpdateBuilderE creates a new instance of an UpdateBuilder in the same manner
+// as NewUpdateBuilder after validating the schema. Every problem found within
+// the schema is reported at once in a ValidationErrors collection of
+// *SchemaError values that include the JSON path of the problem.
+func NewUpdateBuilderE(collection string, schema any, opts ...*updateOptions) (*UpdateBuilder, error) {
+	if err := withCollection(validateSchema(schema), collection); err != nil {
+		return nil, err
+	}
+
+	return NewUpdateBuilder(collection, schema, opts...), nil
+}
+
 // Update creates a suitable bson document to send to any of the update methods
 // exposed by the Mongo driver. This method supports optional additional options
 // that can be used to control the behavior of the update document. Any options
 // provided will override the default options set on the UpdateBuilder instance.
+// When strict validation is enabled, every field on the doc that is not defined
+// in the schema is reported at once in a ValidationErrors collection.
 func (ub *UpdateBuilder) Update(doc any, opts ...*updateOptions) (bson.D, error) {
 	// create the update document and it's components
+	errs := ValidationErrors{}
 	ats := bson.D{}
 	set := bson.D{}
 	us := bson.D{}
@@ -56,7 +71,12 @@ func (ub *UpdateBuilder) Update(doc any, opts ...*updateOptions) (bson.D, error)
 		// but not in the schema
 		if uo.strictValidation != nil && *uo.strictValidation {
 			if _, ok := ub.flds[pth]; !ok {
-				return fmt.Errorf("field %s does not exist in collection %s", pth, ub.clctn)
+				errs.add(&UnknownFieldError{
+					Field:  pth,
+					Value:  fmt.Sprint(val),
+					Reason: "document field is not defined in the schema",
+				})
+				return nil
 			}
 		}
 
@@ -101,6 +121,11 @@ func (ub *UpdateBuilder) Update(doc any, opts ...*updateOptions) (bson.D, error)
 		return upd, err
 	}
 
+	// report every field that failed validation at once
+	if err := withCollection(errs.err(), ub.clctn); err != nil {
+		return upd, err
+	}
+
 	// add the addToSet document to the update document
 	if len(ats) > 0 {
 		upd = append(upd, bson.E{
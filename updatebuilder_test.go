@@ -1,6 +1,7 @@
 package querybuilder
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -120,6 +121,23 @@ func Test_NewUpdateBuilder(t *testing.T) {
 	}
 }
 
+func Test_NewUpdateBuilderE(t *testing.T) {
+	if _, err := NewUpdateBuilderE("things", thingsSchema); err != nil {
+		t.Errorf("NewUpdateBuilderE() error = %v", err)
+	}
+
+	_, err := NewUpdateBuilderE("things", `{"properties": {"name": {"bsonType": 1}}}`)
+
+	var se *SchemaError
+	if !errors.As(err, &se) {
+		t.Fatalf("NewUpdateBuilderE() error = %v, want *SchemaError", err)
+	}
+
+	if se.Path != "$.properties.name.bsonType" || se.Collection != "things" {
+		t.Errorf("NewUpdateBuilderE() error = %v", se)
+	}
+}
+
 func TestUpdateBuilder_Update(t *testing.T) {
 	var thng string = "thing"
 	type fields struct {
@@ -474,3 +492,24 @@ func TestUpdateBuilder_Update(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateBuilder_Update_ValidationErrors(t *testing.T) {
+	type unknown struct {
+		ThingID  string `bson:"thingID"`
+		Missing1 string `bson:"missing1"`
+		Missing2 int    `bson:"missing2"`
+	}
+
+	ub := NewUpdateBuilder("things", thingsSchema, UpdateOptions().SetStrictValidation(true))
+	_, err := ub.Update(unknown{ThingID: "123", Missing1: "a", Missing2: 2})
+
+	var ve ValidationErrors
+	if !errors.As(err, &ve) || len(ve) != 2 {
+		t.Fatalf("UpdateBuilder.Update() error = %v, want 2 ValidationErrors", err)
+	}
+
+	var ufe *UnknownFieldError
+	if !errors.As(err, &ufe) || ufe.Field != "missing1" || ufe.Value != "a" || ufe.Collection != "things" {
+		t.Errorf("UpdateBuilder.Update() UnknownFieldError = %+v", ufe)
+	}
+}